@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+//Управляющие символы, которые обрабатывает редактор
+const (
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyBackspace = 8
+	keyTab       = 9
+	keyNewline   = 10
+	keyEnter     = 13
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+//lineEditor читает строку с терминала в неканоническом режиме:
+//поддерживает историю (стрелки вверх/вниз) и дополнение по Tab
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  func() []string
+	complete func(line string) []string
+}
+
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	line := ""
+	history := e.history()
+	//position указывает на элемент истории; len(history) - текущая строка
+	position := len(history)
+	draft := ""
+
+	fmt.Fprint(e.out, prompt)
+	for {
+		b, err := e.in.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		switch b {
+		case keyEnter, keyNewline:
+			fmt.Fprint(e.out, "\r\n")
+			return line, nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			line = ""
+			position = len(history)
+			fmt.Fprint(e.out, prompt)
+		case keyCtrlD:
+			if line == "" {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+		case keyBackspace, keyDelete:
+			if line != "" {
+				_, size := utf8.DecodeLastRuneInString(line)
+				line = line[:len(line)-size]
+				e.redraw(prompt, line)
+			}
+		case keyTab:
+			line = e.completeLine(prompt, line)
+		case keyEscape:
+			key, err := e.readEscape()
+			if err != nil {
+				return "", err
+			}
+			switch key {
+			case 'A':
+				if position > 0 {
+					if position == len(history) {
+						draft = line
+					}
+					position--
+					line = history[position]
+					e.redraw(prompt, line)
+				}
+			case 'B':
+				if position < len(history) {
+					position++
+					if position == len(history) {
+						line = draft
+					} else {
+						line = history[position]
+					}
+					e.redraw(prompt, line)
+				}
+			}
+		default:
+			if b >= ' ' {
+				line += string([]byte{b})
+				e.out.Write([]byte{b})
+			}
+		}
+	}
+}
+
+//readEscape читает escape-последовательность вида ESC [ X и возвращает X
+func (e *lineEditor) readEscape() (byte, error) {
+	b, err := e.in.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != '[' && b != 'O' {
+		return 0, nil
+	}
+	for {
+		b, err = e.in.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		//параметры последовательности - цифры и ';'
+		if (b < '0' || b > '9') && b != ';' {
+			return b, nil
+		}
+	}
+}
+
+//completeLine дополняет последнее слово строки, а при нескольких вариантах показывает их
+func (e *lineEditor) completeLine(prompt string, line string) string {
+	matches := e.complete(line)
+	if len(matches) == 0 {
+		return line
+	}
+
+	start := strings.LastIndex(line, " ") + 1
+	word := line[start:]
+	if len(matches) == 1 {
+		line = line[:start] + matches[0] + " "
+		e.redraw(prompt, line)
+		return line
+	}
+
+	common := commonPrefix(matches)
+	if len(common) > len(word) {
+		line = line[:start] + common
+		e.redraw(prompt, line)
+		return line
+	}
+
+	fmt.Fprint(e.out, "\r\n"+strings.Join(matches, "  ")+"\r\n")
+	fmt.Fprint(e.out, prompt+line)
+	return line
+}
+
+func (e *lineEditor) redraw(prompt string, line string) {
+	fmt.Fprint(e.out, "\r\x1b[K"+prompt+line)
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -1,38 +1,639 @@
 package main
 
 import (
-	//"fmt"
-	//"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 
-	//"github.com/Behzod01/wallet/pkg/wallet"
+	"github.com/Behzod01/wallet/pkg/types"
+	"github.com/Behzod01/wallet/pkg/wallet"
 )
 
+//Коды завершения программы
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+	exitStorage = 3
+)
+
+//errUsage возвращается, когда команде переданы неверные аргументы
+var errUsage = errors.New("usage")
+
+//storageError оборачивает ошибки чтения/записи каталога с данными
+type storageError struct {
+	err error
+}
+
+func (e *storageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *storageError) Unwrap() error {
+	return e.err
+}
+
+//argKind определяет, чем дополнять аргумент команды в интерактивном режиме
+type argKind int
+
+const (
+	argNone argKind = iota
+	argAccount
+	argPayment
+	argFavorite
+)
+
+//command описывает одну подкоманду
+type command struct {
+	args  string
+	nargs int
+	kinds []argKind
+	run   func(svc *wallet.Service, args []string, out io.Writer) error
+}
+
+var commands = map[string]command{
+	"register": {
+		args:  "<телефон>",
+		nargs: 1,
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			account, err := svc.RegisterAccount(types.Phone(args[0]))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Счёт %d зарегистрирован\n", account.ID)
+			return nil
+		},
+	},
+	"deposit": {
+		args:  "<счёт> <сумма>",
+		nargs: 2,
+		kinds: []argKind{argAccount},
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			accountID, err := parseAccountID(args[0])
+			if err != nil {
+				return err
+			}
+			account, err := svc.FindAccountByID(accountID)
+			if err != nil {
+				return err
+			}
+			amount, err := parseAmount(args[1], account.Currency)
+			if err != nil {
+				return err
+			}
+			err = svc.Deposit(accountID, amount)
+			if err != nil {
+				return err
+			}
+			return printBalance(svc, accountID, out)
+		},
+	},
+	"pay": {
+		args:  "<счёт> <сумма> <категория>",
+		nargs: 3,
+		kinds: []argKind{argAccount},
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			accountID, err := parseAccountID(args[0])
+			if err != nil {
+				return err
+			}
+			account, err := svc.FindAccountByID(accountID)
+			if err != nil {
+				return err
+			}
+			amount, err := parseAmount(args[1], account.Currency)
+			if err != nil {
+				return err
+			}
+			payment, err := svc.Pay(accountID, amount, types.PaymentCategory(args[2]))
+			if err != nil {
+				return err
+			}
+			printPayment(payment, out)
+			return nil
+		},
+	},
+	"reject": {
+		args:  "<платёж>",
+		nargs: 1,
+		kinds: []argKind{argPayment},
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			err := svc.Reject(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Платёж %s отменён\n", args[0])
+			return nil
+		},
+	},
+	"refund": {
+		args:  "<платёж> <сумма>",
+		nargs: 2,
+		kinds: []argKind{argPayment},
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			payment, err := svc.FindPaymentByID(args[0])
+			if err != nil {
+				return err
+			}
+			amount, err := parseAmount(args[1], payment.Currency)
+			if err != nil {
+				return err
+			}
+			refund, err := svc.Refund(payment.ID, amount)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Возврат %s на сумму %s создан\n", refund.ID, refund.Amount.Format(payment.Currency))
+			return nil
+		},
+	},
+	"repeat": {
+		args:  "<платёж>",
+		nargs: 1,
+		kinds: []argKind{argPayment},
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			payment, err := svc.Repeat(args[0])
+			if err != nil {
+				return err
+			}
+			printPayment(payment, out)
+			return nil
+		},
+	},
+	"favorite": {
+		args:  "<платёж> <название>",
+		nargs: 2,
+		kinds: []argKind{argPayment},
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			favorite, err := svc.FavoritePayment(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Избранное %s добавлено\n", favorite.ID)
+			return nil
+		},
+	},
+	"pay-favorite": {
+		args:  "<избранное>",
+		nargs: 1,
+		kinds: []argKind{argFavorite},
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			payment, err := svc.PayFromFavorite(args[0])
+			if err != nil {
+				return err
+			}
+			printPayment(payment, out)
+			return nil
+		},
+	},
+	"balance": {
+		args:  "<счёт>",
+		nargs: 1,
+		kinds: []argKind{argAccount},
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			accountID, err := parseAccountID(args[0])
+			if err != nil {
+				return err
+			}
+			return printBalance(svc, accountID, out)
+		},
+	},
+	//run-scheduled рассчитан на запуск по cron: выполняет наступившие платежи по расписанию
+	//и отменяет платежи с просроченным кодом подтверждения
+	"run-scheduled": {
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			runs := svc.RunScheduledPayments()
+			for _, run := range runs {
+				if run.Error != "" {
+					fmt.Fprintf(out, "Платёж по расписанию %s не выполнен: %s\n", run.ScheduleID, run.Error)
+					continue
+				}
+				fmt.Fprintf(out, "Платёж по расписанию %s выполнен: %s\n", run.ScheduleID, run.PaymentID)
+			}
+			fmt.Fprintf(out, "Выполнено платежей по расписанию: %d\n", len(runs))
+			expired, err := svc.ExpireConfirmations()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Отменено неподтверждённых платежей: %d\n", expired)
+			return nil
+		},
+	},
+	"freeze":   statusCommand((*wallet.Service).Freeze, "Счёт %d заморожен\n"),
+	"unfreeze": statusCommand((*wallet.Service).Unfreeze, "Счёт %d разморожен\n"),
+	"reopen":   statusCommand((*wallet.Service).Reopen, "Счёт %d снова открыт\n"),
+	"change-phone": {
+		args:  "<счёт> <телефон>",
+		nargs: 2,
+		kinds: []argKind{argAccount},
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			accountID, err := parseAccountID(args[0])
+			if err != nil {
+				return err
+			}
+			account, err := svc.ChangePhone(accountID, types.Phone(args[1]))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Счёт %d переведён на номер %s\n", account.ID, account.Phone)
+			return nil
+		},
+	},
+	"close": {
+		args:  "<счёт> <счёт для остатка, 0 - без перевода>",
+		nargs: 2,
+		kinds: []argKind{argAccount, argAccount},
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			accountID, err := parseAccountID(args[0])
+			if err != nil {
+				return err
+			}
+			payoutAccountID, err := parseAccountID(args[1])
+			if err != nil {
+				return err
+			}
+			err = svc.Close(accountID, payoutAccountID)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Счёт %d закрыт\n", accountID)
+			return nil
+		},
+	},
+	"export": {
+		args:  "<каталог>",
+		nargs: 1,
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			err := save(svc, args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Данные выгружены в %s\n", args[0])
+			return nil
+		},
+	},
+	"import": {
+		args:  "<каталог>",
+		nargs: 1,
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			//импортированные данные заменяют текущие
+			*svc = wallet.Service{}
+			err := load(svc, args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Данные загружены из %s\n", args[0])
+			return nil
+		},
+	},
+}
+
+//statusCommand описывает команду, которая меняет статус счёта
+func statusCommand(change func(svc *wallet.Service, accountID int64) error, message string) command {
+	return command{
+		args:  "<счёт>",
+		nargs: 1,
+		kinds: []argKind{argAccount},
+		run: func(svc *wallet.Service, args []string, out io.Writer) error {
+			accountID, err := parseAccountID(args[0])
+			if err != nil {
+				return err
+			}
+			err = change(svc, accountID)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, message, accountID)
+			return nil
+		},
+	}
+}
+
 func main() {
-//	svc := &wallet.Service{}
-/*	err := svc.ExportToFile("accounts.txt")
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+//run выполняет одну команду над каталогом с данными и возвращает код завершения
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("wallet", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dir := flags.String("data", "data", "каталог с данными кошелька")
+	flags.Usage = func() {
+		usage(stderr)
+		flags.PrintDefaults()
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	name := flags.Arg(0)
+	_, ok := commands[name]
+	if !ok && name != "shell" {
+		fmt.Fprintf(stderr, "Неизвестная команда: %s\n", name)
+		usage(stderr)
+		return exitUsage
+	}
+
+	svc := &wallet.Service{}
+	err = load(svc, *dir)
+	if err != nil {
+		fmt.Fprintln(stderr, localize(err))
+		return exitCode(err)
+	}
+
+	if name == "shell" {
+		return runShell(svc, *dir, stdin, stdout, stderr)
+	}
+
+	code := execute(svc, name, flags.Args()[1:], stdout, stderr)
+	if code != exitOK {
+		return code
+	}
+
+	err = save(svc, *dir)
+	if err != nil {
+		fmt.Fprintln(stderr, localize(err))
+		return exitCode(err)
+	}
+	return exitOK
+}
+
+//execute выполняет подкоманду над загруженным кошельком, не сохраняя результат
+func execute(svc *wallet.Service, name string, args []string, stdout io.Writer, stderr io.Writer) int {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "Неизвестная команда: %s\n", name)
+		return exitUsage
+	}
+	if len(args) != cmd.nargs {
+		fmt.Fprintf(stderr, "Использование: %s %s\n", name, cmd.args)
+		return exitUsage
+	}
+
+	err := cmd.run(svc, args, stdout)
+	if err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "Использование: %s %s\n", name, cmd.args)
+		} else {
+			fmt.Fprintln(stderr, localize(err))
+		}
+		return exitCode(err)
+	}
+	return exitOK
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Использование: wallet [-data каталог] <команда> [аргументы]")
+	fmt.Fprintln(w, "Команды:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s %s\n", name, commands[name].args)
+	}
+	fmt.Fprintln(w, "  shell (интерактивный режим)")
+}
+
+//load загружает данные из каталога; отсутствующий каталог означает пустой кошелёк
+func load(svc *wallet.Service, dir string) error {
+	_, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return &storageError{err}
+	}
+	err = svc.Import(dir)
+	if err != nil {
+		return &storageError{err}
+	}
+	return nil
+}
+
+//save заменяет дампы каталога текущими данными. Данные сначала выгружаются во временный
+//каталог, а старые дампы удаляются, чтобы после import в каталоге не остались чужие записи
+func save(svc *wallet.Service, dir string) error {
+	err := os.MkdirAll(dir, 0755)
 	if err != nil {
-		log.Print()		
+		return &storageError{err}
 	}
-	*/
-/*	vizov obichnoy funksii
-	wallet.RegisterAccount(svc, "+992000000001")
-	vizov metoda
-	svc.RegisterAccount("+992000000001")*/
-	/*account, err := svc.RegisterAccount("+992000000001")
+	tmp, err := os.MkdirTemp(dir, ".save-")
 	if err != nil {
-		fmt.Println(err)
+		return &storageError{err}
 	}
+	defer os.RemoveAll(tmp)
 
-	err = svc.Deposit(account.ID, 10)
+	err = svc.Export(tmp)
 	if err != nil {
-		switch err {
-		case wallet.ErrAmountMustBePositive:
-			fmt.Println("Сумма должна быть положительной")
-		case wallet.ErrAccountNotFound:
-			fmt.Println("Аккаунт пользователя не найден")			
+		return &storageError{err}
+	}
+	stale, err := filepath.Glob(filepath.Join(dir, "*.dump"))
+	if err != nil {
+		return &storageError{err}
+	}
+	for _, path := range stale {
+		err = os.Remove(path)
+		if err != nil {
+			return &storageError{err}
+		}
+	}
+	dumps, err := filepath.Glob(filepath.Join(tmp, "*.dump"))
+	if err != nil {
+		return &storageError{err}
+	}
+	for _, path := range dumps {
+		err = os.Rename(path, filepath.Join(dir, filepath.Base(path)))
+		if err != nil {
+			return &storageError{err}
 		}
-	return
-	}	
-	fmt.Println(account.Balance)//10*/
+	}
+	return nil
+}
+
+func printBalance(svc *wallet.Service, accountID int64, out io.Writer) error {
+	account, err := svc.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Баланс счёта %d: %s\n", account.ID, account.Balance.Format(account.Currency))
+	return nil
+}
+
+func printPayment(payment *types.Payment, out io.Writer) {
+	if payment.Fee > 0 {
+		fmt.Fprintf(out, "Платёж %s создан, комиссия %s\n", payment.ID, payment.Fee.Format(payment.Currency))
+		return
+	}
+	fmt.Fprintf(out, "Платёж %s создан\n", payment.ID)
+}
+
+func parseAccountID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errUsage
+	}
+	return id, nil
+}
 
-}
\ No newline at end of file
+//parseAmount разбирает сумму в валюте счёта так же, как она показывается: "10", "10,50", "1234.56"
+func parseAmount(s string, currency types.Currency) (types.Money, error) {
+	amount, err := types.ParseMoney(s, currency)
+	if errors.Is(err, types.ErrInvalidMoney) {
+		return 0, errUsage
+	}
+	if err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
+
+func exitCode(err error) int {
+	var storageErr *storageError
+	switch {
+	case errors.Is(err, errUsage):
+		return exitUsage
+	case errors.As(err, &storageErr):
+		return exitStorage
+	}
+	return exitFailure
+}
+
+//limitName возвращает название лимита для сообщений пользователю
+func limitName(err *wallet.LimitError) string {
+	switch err.Kind {
+	case types.LimitPerTransaction:
+		return "на один платёж"
+	case types.LimitDaily:
+		return "дневной"
+	case types.LimitMonthly:
+		return "месячный"
+	}
+	return "категория " + string(err.Category)
+}
+
+//localize переводит ошибки кошелька в сообщения для пользователя
+func localize(err error) string {
+	var storageErr *storageError
+	var limitErr *wallet.LimitError
+	switch {
+	case errors.Is(err, wallet.ErrAccountNotFound):
+		return "Аккаунт пользователя не найден"
+	case errors.Is(err, wallet.ErrPhoneRegistered):
+		return "Телефон уже зарегистрирован"
+	case errors.Is(err, wallet.ErrAccountFrozen):
+		return "Счёт заморожен"
+	case errors.Is(err, wallet.ErrAccountClosed):
+		return "Счёт закрыт"
+	case errors.Is(err, wallet.ErrAccountNotEmpty):
+		return "На счёте остались деньги, укажите счёт для перевода остатка"
+	case errors.Is(err, wallet.ErrInvalidPayout):
+		return "Остаток нельзя перевести на закрываемый счёт"
+	case errors.Is(err, wallet.ErrTierLimitExceeded):
+		return "Превышено ограничение уровня идентификации, пройдите идентификацию"
+	case errors.Is(err, wallet.ErrInvalidProfile):
+		return "Укажите имя и дату рождения"
+	case errors.Is(err, wallet.ErrInvalidTier):
+		return "Неверный уровень идентификации"
+	case errors.Is(err, wallet.ErrProfileIncomplete):
+		return "Анкета не заполнена для этого уровня"
+	case errors.Is(err, wallet.ErrUpgradeNotFound):
+		return "Заявка не найдена"
+	case errors.Is(err, wallet.ErrUpgradePending):
+		return "Заявка на повышение уровня уже рассматривается"
+	case errors.Is(err, wallet.ErrUpgradeNotPending):
+		return "Заявка уже рассмотрена"
+	case errors.Is(err, wallet.ErrInvalidPIN):
+		return "ПИН-код должен состоять из 4-6 цифр"
+	case errors.Is(err, wallet.ErrPINNotSet):
+		return "ПИН-код не задан"
+	case errors.Is(err, wallet.ErrWrongPIN):
+		return "Неверный ПИН-код"
+	case errors.Is(err, wallet.ErrPINLocked):
+		return "ПИН-код заблокирован, попробуйте позже"
+	case errors.Is(err, wallet.ErrNoCodeNotifier):
+		return "Не удалось отправить код подтверждения"
+	case errors.Is(err, wallet.ErrPaymentNotPending):
+		return "Платёж не ожидает подтверждения"
+	case errors.Is(err, wallet.ErrPaymentNotConfirmed):
+		return "Платёж ещё не подтверждён"
+	case errors.Is(err, wallet.ErrWrongCode):
+		return "Неверный код подтверждения"
+	case errors.Is(err, wallet.ErrCodeExpired):
+		return "Срок действия кода истёк, платёж отменён"
+	case errors.Is(err, wallet.ErrCodeAttemptsExceeded):
+		return "Попытки ввода кода исчерпаны, платёж отменён"
+	case errors.Is(err, wallet.ErrInvalidPhone):
+		return "Неверный номер телефона"
+	case errors.Is(err, wallet.ErrUnsupportedCountry):
+		return "Номера этой страны не поддерживаются"
+	case errors.Is(err, wallet.ErrAmountMustBePositive):
+		return "Сумма должна быть положительной"
+	case errors.Is(err, wallet.ErrPaymentNotFound):
+		return "Платёж не найден"
+	case errors.Is(err, types.ErrMoneyOverflow):
+		return "Сумма слишком велика"
+	case errors.Is(err, wallet.ErrCashbackSpent):
+		return "Кэшбэк по платежу уже потрачен, возврат невозможен"
+	case errors.Is(err, wallet.ErrNotEnoughBalance):
+		return "Недостаточно средств на счёте"
+	case errors.Is(err, wallet.ErrFavoriteNotFound):
+		return "Избранное не найдено"
+	case errors.Is(err, wallet.ErrFavoriteNameEmpty):
+		return "Название избранного не может быть пустым"
+	case errors.Is(err, wallet.ErrFavoriteNameTaken):
+		return "Избранное с таким названием уже есть"
+	case errors.Is(err, wallet.ErrPaymentRejected):
+		return "Платёж уже отменён"
+	case errors.Is(err, wallet.ErrRefundExceedsPayment):
+		return "Сумма возврата превышает остаток платежа"
+	case errors.Is(err, wallet.ErrInvalidTemplate):
+		return "Неверные поля шаблона"
+	case errors.Is(err, wallet.ErrTemplateAmountRequired):
+		return "Для этого шаблона нужно указать сумму"
+	case errors.Is(err, wallet.ErrTemplateAmountFixed):
+		return "Сумма этого шаблона не меняется"
+	case errors.Is(err, wallet.ErrTemplateFieldRequired):
+		return "Не заполнено обязательное поле шаблона"
+	case errors.Is(err, wallet.ErrTemplateFieldUnknown):
+		return "В шаблоне нет такого поля"
+	case errors.Is(err, wallet.ErrEnvelopeNotFound):
+		return "Конверт не найден"
+	case errors.Is(err, wallet.ErrEnvelopeNameEmpty):
+		return "Название конверта не может быть пустым"
+	case errors.Is(err, wallet.ErrEnvelopeNameTaken):
+		return "Конверт с таким названием уже есть"
+	case errors.Is(err, wallet.ErrGoalNotFound):
+		return "Цель не найдена"
+	case errors.Is(err, wallet.ErrGoalExists):
+		return "У конверта уже есть цель"
+	case errors.Is(err, wallet.ErrUnsupportedCurrency):
+		return "Валюта не поддерживается"
+	case errors.Is(err, wallet.ErrCurrencyMismatch):
+		return "Валюта не совпадает с валютой счёта"
+	case errors.Is(err, wallet.ErrConversionNotAllowed):
+		return "Обмен возможен только между своими счетами в разных валютах"
+	case errors.Is(err, wallet.ErrRateNotFound):
+		return "Курс обмена недоступен"
+	case errors.Is(err, wallet.ErrUnknownCategory):
+		return "Неизвестная категория платежа"
+	case errors.Is(err, wallet.ErrInvalidLimits):
+		return "Лимит не может быть отрицательным"
+	case errors.As(err, &limitErr):
+		return fmt.Sprintf("Превышен лимит расходов (%s): доступно %s", limitName(limitErr), limitErr.Remaining.Format(limitErr.Currency))
+	case errors.As(err, &storageErr):
+		return "Ошибка работы с данными: " + storageErr.Error()
+	}
+	return "Ошибка: " + err.Error()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Behzod01/wallet/pkg/types"
+	"github.com/Behzod01/wallet/pkg/wallet"
+)
+
+func runTest(t *testing.T, dir string, args ...string) (int, string, string) {
+	t.Helper()
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := run(append([]string{"-data", dir}, args...), strings.NewReader(""), stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_registerDepositPay(t *testing.T) {
+	dir := t.TempDir()
+
+	code, out, errOut := runTest(t, dir, "register", "+992000000001")
+	if code != exitOK {
+		t.Fatalf("register: code = %d, stderr = %s", code, errOut)
+	}
+	if !strings.Contains(out, "Счёт 1") {
+		t.Errorf("register: wrong output = %s", out)
+	}
+
+	code, _, errOut = runTest(t, dir, "deposit", "1", "100")
+	if code != exitOK {
+		t.Fatalf("deposit: code = %d, stderr = %s", code, errOut)
+	}
+
+	code, _, errOut = runTest(t, dir, "pay", "1", "29,50", "auto")
+	if code != exitOK {
+		t.Fatalf("pay: code = %d, stderr = %s", code, errOut)
+	}
+
+	code, out, _ = runTest(t, dir, "balance", "1")
+	if code != exitOK || !strings.Contains(out, "70,50 TJS") {
+		t.Errorf("balance: code = %d, output = %s", code, out)
+	}
+
+	code, out, _ = runTest(t, dir, "register", "+992000000002")
+	if code != exitOK || !strings.Contains(out, "Счёт 2") {
+		t.Errorf("register after import must continue ids: code = %d, output = %s", code, out)
+	}
+}
+
+func TestRun_errors(t *testing.T) {
+	dir := t.TempDir()
+
+	code, _, _ := runTest(t, dir)
+	if code != exitUsage {
+		t.Errorf("no command: code = %d, want %d", code, exitUsage)
+	}
+
+	code, _, _ = runTest(t, dir, "unknown")
+	if code != exitUsage {
+		t.Errorf("unknown command: code = %d, want %d", code, exitUsage)
+	}
+
+	code, _, _ = runTest(t, dir, "deposit", "1")
+	if code != exitUsage {
+		t.Errorf("missing argument: code = %d, want %d", code, exitUsage)
+	}
+
+	code, _, errOut := runTest(t, dir, "deposit", "1", "100")
+	if code != exitFailure {
+		t.Errorf("unknown account: code = %d, want %d", code, exitFailure)
+	}
+	if !strings.Contains(errOut, "Аккаунт пользователя не найден") {
+		t.Errorf("unknown account: wrong message = %s", errOut)
+	}
+
+	runTest(t, dir, "register", "+992000000001")
+	code, _, errOut = runTest(t, dir, "pay", "1", "100", "auto")
+	if code != exitFailure || !strings.Contains(errOut, "Недостаточно средств") {
+		t.Errorf("not enough balance: code = %d, stderr = %s", code, errOut)
+	}
+	code, _, _ = runTest(t, dir, "deposit", "1", "10,505")
+	if code != exitUsage {
+		t.Errorf("too many decimals: code = %d, want %d", code, exitUsage)
+	}
+}
+
+func TestRun_exportImport(t *testing.T) {
+	dir := t.TempDir()
+	backup := t.TempDir()
+
+	runTest(t, dir, "register", "+992000000001")
+	runTest(t, dir, "deposit", "1", "5")
+	code, _, errOut := runTest(t, dir, "export", backup)
+	if code != exitOK {
+		t.Fatalf("export: code = %d, stderr = %s", code, errOut)
+	}
+
+	//импорт заменяет все данные каталога, старые платежи и избранное не остаются
+	other := t.TempDir()
+	runTest(t, other, "register", "+992000000002")
+	runTest(t, other, "deposit", "1", "1000")
+	runTest(t, other, "pay", "1", "100", "auto")
+	err := os.WriteFile(filepath.Join(other, "legacy.dump"), []byte("1;1;1\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, errOut = runTest(t, other, "import", backup)
+	if code != exitOK {
+		t.Fatalf("import: code = %d, stderr = %s", code, errOut)
+	}
+	_, err = os.Stat(filepath.Join(other, "legacy.dump"))
+	if !os.IsNotExist(err) {
+		t.Errorf("import: stale dump must be removed, error = %v", err)
+	}
+	payments, err := os.ReadFile(filepath.Join(other, "payments.dump"))
+	if err != nil || len(payments) != 0 {
+		t.Errorf("import: payments.dump = %q, error = %v", payments, err)
+	}
+	code, out, _ := runTest(t, other, "balance", "1")
+	if code != exitOK || !strings.Contains(out, "5,00 TJS") {
+		t.Errorf("balance after import: code = %d, output = %s", code, out)
+	}
+}
+
+func TestRun_runScheduled(t *testing.T) {
+	dir := t.TempDir()
+	code, out, errOut := runTest(t, dir, "run-scheduled")
+	if code != exitOK || !strings.Contains(out, "Выполнено платежей по расписанию: 0") {
+		t.Errorf("run-scheduled: code = %d, output = %s, stderr = %s", code, out, errOut)
+	}
+}
+
+func TestLocalize_limit(t *testing.T) {
+	err := &wallet.LimitError{Kind: types.LimitDaily, Currency: types.TJS, Limit: 10_00, Remaining: 1_50}
+	if got := localize(err); !strings.Contains(got, "доступно 1,50 TJS") {
+		t.Errorf("localize() = %s", got)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Behzod01/wallet/pkg/wallet"
+)
+
+const shellPrompt = "wallet> "
+
+//lineReader читает очередную строку, введённую пользователем
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+//shell хранит состояние интерактивного режима
+type shell struct {
+	svc     *wallet.Service
+	dir     string
+	stdout  io.Writer
+	stderr  io.Writer
+	history []string
+}
+
+//shellCommands - команды, доступные только в интерактивном режиме
+var shellCommands = []string{"exit", "help", "history", "quit", "save"}
+
+//runShell запускает интерактивный режим; данные сохраняются командой save и при выходе
+func runShell(svc *wallet.Service, dir string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	sh := &shell{
+		svc:    svc,
+		dir:    dir,
+		stdout: stdout,
+		stderr: stderr,
+	}
+
+	reader, restore := sh.newLineReader(stdin)
+	defer restore()
+
+	fmt.Fprintln(stdout, "Введите help для списка команд, exit для выхода")
+	for {
+		line, err := reader.ReadLine(shellPrompt)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, localize(err))
+			return exitFailure
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		sh.history = append(sh.history, line)
+
+		name, args := fields[0], fields[1:]
+		if name == "exit" || name == "quit" {
+			break
+		}
+		sh.execute(name, args)
+	}
+
+	err := save(sh.svc, sh.dir)
+	if err != nil {
+		fmt.Fprintln(stderr, localize(err))
+		return exitCode(err)
+	}
+	return exitOK
+}
+
+//newLineReader включает редактирование строки, если ввод идёт с терминала
+func (sh *shell) newLineReader(stdin io.Reader) (lineReader, func()) {
+	file, ok := stdin.(*os.File)
+	if ok {
+		restore, err := makeRaw(file)
+		if err == nil {
+			editor := &lineEditor{
+				in:       bufio.NewReader(file),
+				out:      sh.stdout,
+				history:  func() []string { return sh.history },
+				complete: sh.complete,
+			}
+			return editor, restore
+		}
+	}
+	return &plainReader{in: bufio.NewScanner(stdin), out: sh.stdout}, func() {}
+}
+
+func (sh *shell) execute(name string, args []string) {
+	switch name {
+	case "help":
+		usage(sh.stdout)
+		fmt.Fprintln(sh.stdout, "Команды интерактивного режима: "+strings.Join(shellCommands, ", "))
+	case "history":
+		for i, line := range sh.history {
+			fmt.Fprintf(sh.stdout, "%4d  %s\n", i+1, line)
+		}
+	case "save":
+		err := save(sh.svc, sh.dir)
+		if err != nil {
+			fmt.Fprintln(sh.stderr, localize(err))
+			return
+		}
+		fmt.Fprintf(sh.stdout, "Данные сохранены в %s\n", sh.dir)
+	default:
+		execute(sh.svc, name, args, sh.stdout, sh.stderr)
+	}
+}
+
+//complete возвращает варианты дополнения последнего слова строки
+func (sh *shell) complete(line string) []string {
+	fields := strings.Fields(line)
+	prefix := ""
+	if len(fields) > 0 && !strings.HasSuffix(line, " ") {
+		//дополняем начатое слово
+		prefix = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+
+	var candidates []string
+	if len(fields) == 0 {
+		candidates = append(candidates, shellCommands...)
+		for name := range commands {
+			candidates = append(candidates, name)
+		}
+	} else {
+		cmd, ok := commands[fields[0]]
+		position := len(fields) - 1
+		if !ok || position >= len(cmd.kinds) {
+			return nil
+		}
+		candidates = sh.values(cmd.kinds[position])
+	}
+
+	matches := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+//values возвращает идентификаторы, подходящие для аргумента данного вида
+func (sh *shell) values(kind argKind) []string {
+	var values []string
+	switch kind {
+	case argAccount:
+		for _, account := range sh.svc.Accounts() {
+			values = append(values, strconv.FormatInt(account.ID, 10))
+		}
+	case argPayment:
+		for _, payment := range sh.svc.Payments() {
+			values = append(values, payment.ID)
+		}
+	case argFavorite:
+		for _, favorite := range sh.svc.Favorites() {
+			values = append(values, favorite.ID)
+		}
+	}
+	return values
+}
+
+//plainReader читает строки без редактирования, например из файла или канала
+type plainReader struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.in.Scan() {
+		fmt.Fprintln(r.out)
+		if r.in.Err() != nil {
+			return "", r.in.Err()
+		}
+		return "", io.EOF
+	}
+	return r.in.Text(), nil
+}
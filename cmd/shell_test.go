@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Behzod01/wallet/pkg/wallet"
+)
+
+func TestRunShell_saveOnExit(t *testing.T) {
+	dir := t.TempDir()
+	input := strings.Join([]string{
+		"register +992000000001",
+		"deposit 1 10",
+		"pay 1 3 auto",
+		"pay 1 50 auto",
+		"history",
+		"exit",
+	}, "\n")
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	code := run([]string{"-data", dir, "shell"}, strings.NewReader(input), stdout, stderr)
+	if code != exitOK {
+		t.Fatalf("shell: code = %d, stderr = %s", code, stderr)
+	}
+	if !strings.Contains(stderr.String(), "Недостаточно средств") {
+		t.Errorf("shell: error must be printed and shell continued, stderr = %s", stderr)
+	}
+	if !strings.Contains(stdout.String(), "   3  pay 1 3 auto") {
+		t.Errorf("shell: history not printed, stdout = %s", stdout)
+	}
+
+	code, out, _ := runTest(t, dir, "balance", "1")
+	if code != exitOK || !strings.Contains(out, "7,00 TJS") {
+		t.Errorf("balance after shell: code = %d, output = %s", code, out)
+	}
+}
+
+func TestShell_complete(t *testing.T) {
+	svc := &wallet.Service{}
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.RegisterAccount("+992000000002")
+	svc.Deposit(account.ID, 100)
+	payment, _ := svc.Pay(account.ID, 10, "auto")
+	sh := &shell{svc: svc}
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "pa", want: []string{"pay", "pay-favorite"}},
+		{line: "balance ", want: []string{"1", "2"}},
+		{line: "reject " + payment.ID[:4], want: []string{payment.ID}},
+		{line: "pay-favorite ", want: []string{}},
+		{line: "register ", want: nil},
+	}
+	for _, test := range tests {
+		got := sh.complete(test.line)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("complete(%q) = %v, want %v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestLineEditor_historyAndCompletion(t *testing.T) {
+	history := []string{"balance 1", "deposit 1 100"}
+	input := "\x1b[A\x1b[A\r" + "bal\t2\x7f1\r"
+	out := &bytes.Buffer{}
+	editor := &lineEditor{
+		in:      bufio.NewReader(strings.NewReader(input)),
+		out:     out,
+		history: func() []string { return history },
+		complete: func(line string) []string {
+			if strings.HasPrefix("balance", line) {
+				return []string{"balance"}
+			}
+			return nil
+		},
+	}
+
+	line, err := editor.ReadLine("> ")
+	if err != nil || line != "balance 1" {
+		t.Errorf("history: line = %q, err = %v", line, err)
+	}
+	line, err = editor.ReadLine("> ")
+	if err != nil || line != "balance 1" {
+		t.Errorf("completion: line = %q, err = %v", line, err)
+	}
+	_, err = editor.ReadLine("> ")
+	if err == nil {
+		t.Errorf("end of input: must return error")
+	}
+}
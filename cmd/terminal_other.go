@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+//makeRaw на других платформах не поддерживается: строки читаются без редактирования
+func makeRaw(file *os.File) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported")
+}
@@ -0,0 +1,16 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//openAPISpec - описание API в формате OpenAPI 3; должно совпадать с маршрутами сервера
+//go:embed openapi.json
+var openAPISpec []byte
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Behzod01/wallet/pkg/wallet"
+)
+
+type openAPIDocument struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func TestOpenAPI_matchesRoutes(t *testing.T) {
+	var doc openAPIDocument
+	err := json.Unmarshal(openAPISpec, &doc)
+	if err != nil {
+		t.Fatalf("can't parse openapi.json, error = %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("wrong openapi version %q", doc.OpenAPI)
+	}
+
+	documented := make([]string, 0)
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+	registered := make([]string, 0)
+	for _, route := range New(&wallet.Service{}).routes {
+		registered = append(registered, route.method+" "+route.pattern)
+	}
+	sort.Strings(documented)
+	sort.Strings(registered)
+
+	if strings.Join(documented, "\n") != strings.Join(registered, "\n") {
+		t.Errorf("openapi.json is out of sync with routes\ndocumented:\n%s\nregistered:\n%s",
+			strings.Join(documented, "\n"), strings.Join(registered, "\n"))
+	}
+}
+
+func TestOpenAPI_served(t *testing.T) {
+	ts := newTestServer(t)
+	var doc openAPIDocument
+	status := do(t, ts, http.MethodGet, "/openapi.json", "", &doc)
+	if status != http.StatusOK || len(doc.Paths) == 0 {
+		t.Errorf("GET /openapi.json: status = %d, paths = %d", status, len(doc.Paths))
+	}
+}
@@ -0,0 +1,1396 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+	"github.com/Behzod01/wallet/pkg/wallet"
+)
+
+var errBadRequest = errors.New("bad request")
+
+//idempotencyKeyHeader - заголовок, по которому повторные запросы не выполняются дважды
+const idempotencyKeyHeader = "Idempotency-Key"
+
+//handlerFunc обрабатывает запрос; params содержит значения параметров пути
+type handlerFunc func(w http.ResponseWriter, r *http.Request, params map[string]string)
+
+//route описывает один маршрут вида "/accounts/{id}/deposits"
+type route struct {
+	method  string
+	pattern string
+	handler handlerFunc
+}
+
+//Server предоставляет wallet.Service как JSON REST API
+type Server struct {
+	mu     sync.Mutex
+	svc    *wallet.Service
+	routes []route
+}
+
+//New создаёт сервер поверх сервиса; все обращения к сервису выполняются последовательно
+func New(svc *wallet.Service) *Server {
+	s := &Server{svc: svc}
+	s.handle(http.MethodGet, "/openapi.json", s.handleOpenAPI)
+	s.handle(http.MethodGet, "/accounts", s.handleAccounts)
+	s.handle(http.MethodPost, "/accounts", s.handleRegisterAccount)
+	s.handle(http.MethodGet, "/accounts/{id}", s.handleAccount)
+	s.handle(http.MethodPost, "/accounts/{id}/deposits", s.handleDeposit)
+	s.handle(http.MethodPost, "/accounts/{id}/freeze", s.handleAccountStatus(s.svc.Freeze))
+	s.handle(http.MethodPost, "/accounts/{id}/unfreeze", s.handleAccountStatus(s.svc.Unfreeze))
+	s.handle(http.MethodPost, "/accounts/{id}/reopen", s.handleAccountStatus(s.svc.Reopen))
+	s.handle(http.MethodPost, "/accounts/{id}/close", s.handleCloseAccount)
+	s.handle(http.MethodPut, "/accounts/{id}/phone", s.handleChangePhone)
+	s.handle(http.MethodGet, "/accounts/{id}/phones", s.handlePhoneHistory)
+	s.handle(http.MethodPut, "/accounts/{id}/pin", s.handleSetPIN)
+	s.handle(http.MethodPost, "/accounts/{id}/pin/verify", s.handleVerifyPIN)
+	s.handle(http.MethodGet, "/accounts/{id}/profile", s.handleProfile)
+	s.handle(http.MethodPut, "/accounts/{id}/profile", s.handleSetProfile)
+	s.handle(http.MethodGet, "/accounts/{id}/upgrades", s.handleUpgradeRequests)
+	s.handle(http.MethodPost, "/accounts/{id}/upgrades", s.handleRequestUpgrade)
+	s.handle(http.MethodPost, "/upgrades/{id}/approve", s.handleApproveUpgrade)
+	s.handle(http.MethodPost, "/upgrades/{id}/reject", s.handleRejectUpgrade)
+	s.handle(http.MethodGet, "/accounts/{id}/favorites", s.handleAccountFavorites)
+	s.handle(http.MethodGet, "/accounts/{id}/limits", s.handleLimits)
+	s.handle(http.MethodPut, "/accounts/{id}/limits", s.handleSetLimits)
+	s.handle(http.MethodGet, "/accounts/{id}/report", s.handleCategoryReport)
+	s.handle(http.MethodGet, "/accounts/{id}/budgets", s.handleBudgets)
+	s.handle(http.MethodPut, "/accounts/{id}/budgets/{category}", s.handleSetBudget)
+	s.handle(http.MethodDelete, "/accounts/{id}/budgets/{category}", s.handleRemoveBudget)
+	s.handle(http.MethodGet, "/accounts/{id}/conversions", s.handleAccountConversions)
+	s.handle(http.MethodPost, "/conversions", s.handleConvert)
+	s.handle(http.MethodGet, "/accounts/{id}/envelopes", s.handleAccountEnvelopes)
+	s.handle(http.MethodPost, "/accounts/{id}/envelopes", s.handleCreateEnvelope)
+	s.handle(http.MethodGet, "/envelopes/{id}", s.handleEnvelope)
+	s.handle(http.MethodDelete, "/envelopes/{id}", s.handleRemoveEnvelope)
+	s.handle(http.MethodPost, "/envelopes/{id}/deposits", s.handleMoveToEnvelope)
+	s.handle(http.MethodPost, "/envelopes/{id}/withdrawals", s.handleMoveFromEnvelope)
+	s.handle(http.MethodPost, "/envelopes/{id}/payments", s.handlePayFromEnvelope)
+	s.handle(http.MethodPost, "/envelopes/{id}/goal", s.handleCreateGoal)
+	s.handle(http.MethodGet, "/goals/{id}", s.handleGoal)
+	s.handle(http.MethodGet, "/goals/{id}/progress", s.handleGoalProgress)
+	s.handle(http.MethodPut, "/goals/{id}/round-up", s.handleSetGoalRoundUp)
+	s.handle(http.MethodGet, "/categories", s.handleCategories)
+	s.handle(http.MethodPost, "/categories", s.handleRegisterCategory)
+	s.handle(http.MethodGet, "/categories/{code}", s.handleCategory)
+	s.handle(http.MethodPost, "/payments", s.handlePay)
+	s.handle(http.MethodGet, "/payments/{id}", s.handlePayment)
+	s.handle(http.MethodPost, "/payments/{id}/reject", s.handleReject)
+	s.handle(http.MethodPost, "/payments/{id}/confirm", s.handleConfirmPayment)
+	s.handle(http.MethodPost, "/payments/{id}/repeat", s.handleRepeat)
+	s.handle(http.MethodGet, "/payments/{id}/refunds", s.handleRefunds)
+	s.handle(http.MethodPost, "/payments/{id}/refunds", s.handleRefund)
+	s.handle(http.MethodPost, "/favorites", s.handleFavoritePayment)
+	s.handle(http.MethodGet, "/favorites/{id}", s.handleFavorite)
+	s.handle(http.MethodPut, "/favorites/{id}", s.handleUpdateFavorite)
+	s.handle(http.MethodDelete, "/favorites/{id}", s.handleRemoveFavorite)
+	s.handle(http.MethodPost, "/favorites/{id}/position", s.handleMoveFavorite)
+	s.handle(http.MethodPut, "/favorites/{id}/template", s.handleSetTemplate)
+	s.handle(http.MethodPost, "/favorites/{id}/payments", s.handlePayFromFavorite)
+	return s
+}
+
+//RunScheduler раз в interval выполняет наступившие платежи по расписанию и отменяет
+//платежи с просроченным кодом подтверждения, пока ctx не отменён
+func (s *Server) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runScheduled()
+		}
+	}
+}
+
+func (s *Server) runScheduled() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, run := range s.svc.RunScheduledPayments() {
+		if run.Error != "" {
+			log.Printf("scheduled payment %s failed: %s", run.ScheduleID, run.Error)
+		}
+	}
+	_, err := s.svc.ExpireConfirmations()
+	if err != nil {
+		log.Printf("can't expire confirmations: %v", err)
+	}
+}
+
+func (s *Server) handle(method string, pattern string, handler handlerFunc) {
+	s.routes = append(s.routes, route{method: method, pattern: pattern, handler: handler})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	allowed := make([]string, 0)
+	for _, route := range s.routes {
+		params, ok := match(route.pattern, r.URL.Path)
+		if !ok {
+			continue
+		}
+		if route.method != r.Method {
+			allowed = append(allowed, route.method)
+			continue
+		}
+		route.handler(w, r, params)
+		return
+	}
+
+	if len(allowed) > 0 {
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeError(w, http.StatusNotFound, "not found")
+}
+
+//match сопоставляет путь с шаблоном и извлекает параметры
+func match(pattern string, path string) (map[string]string, bool) {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if pathParts[i] == "" {
+				return nil, false
+			}
+			params[part[1:len(part)-1]] = pathParts[i]
+			continue
+		}
+		if part != pathParts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+type registerRequest struct {
+	Phone    types.Phone    `json:"phone"`
+	Currency types.Currency `json:"currency"`
+}
+
+//depositRequest и payRequest принимают необязательную валюту;
+//если она указана, она должна совпадать с валютой счёта
+type depositRequest struct {
+	Amount   types.Money    `json:"amount"`
+	Currency types.Currency `json:"currency"`
+}
+
+//payRequest содержит ПИН-код, если он задан у счёта
+type payRequest struct {
+	AccountID int64                 `json:"accountId"`
+	Amount    types.Money           `json:"amount"`
+	Currency  types.Currency        `json:"currency"`
+	Category  types.PaymentCategory `json:"category"`
+	PIN       string                `json:"pin"`
+}
+
+type confirmRequest struct {
+	Code string `json:"code"`
+}
+
+type refundRequest struct {
+	Amount types.Money `json:"amount"`
+}
+
+type favoriteRequest struct {
+	PaymentID string `json:"paymentId"`
+	Name      string `json:"name"`
+}
+
+type updateFavoriteRequest struct {
+	Name   string      `json:"name"`
+	Amount types.Money `json:"amount"`
+}
+
+type moveFavoriteRequest struct {
+	Position int `json:"position"`
+}
+
+//phoneRequest, closeRequest, convertRequest и envelopePayRequest, как и
+//payRequest, содержат ПИН-код, если он задан у счёта
+type phoneRequest struct {
+	Phone types.Phone `json:"phone"`
+	PIN   string      `json:"pin"`
+}
+
+type setPINRequest struct {
+	PIN        string `json:"pin"`
+	CurrentPIN string `json:"currentPin"`
+}
+
+type verifyPINRequest struct {
+	PIN string `json:"pin"`
+}
+
+type upgradeRequest struct {
+	Tier types.KYCTier `json:"tier"`
+}
+
+type rejectUpgradeRequest struct {
+	Reason string `json:"reason"`
+}
+
+type closeRequest struct {
+	PayoutAccountID int64  `json:"payoutAccountId"`
+	PIN             string `json:"pin"`
+}
+
+type convertRequest struct {
+	FromAccountID int64       `json:"fromAccountId"`
+	ToAccountID   int64       `json:"toAccountId"`
+	Amount        types.Money `json:"amount"`
+	PIN           string      `json:"pin"`
+}
+
+type envelopeRequest struct {
+	Name string `json:"name"`
+}
+
+type envelopePayRequest struct {
+	Amount   types.Money           `json:"amount"`
+	Category types.PaymentCategory `json:"category"`
+	PIN      string                `json:"pin"`
+}
+
+//favoritePayRequest дополняет изменения шаблона ПИН-кодом счёта
+type favoritePayRequest struct {
+	types.TemplateOverrides
+	PIN string `json:"pin"`
+}
+
+type goalRequest struct {
+	Target   types.Money `json:"target"`
+	Deadline time.Time   `json:"deadline"`
+}
+
+type roundUpRequest struct {
+	Unit types.Money `json:"unit"`
+}
+
+type budgetRequest struct {
+	Amount types.Money `json:"amount"`
+}
+
+type templateRequest struct {
+	VariableAmount bool                  `json:"variableAmount"`
+	Fields         []types.TemplateField `json:"fields"`
+}
+
+//errorResponse - тело ответа при ошибке
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) handleAccounts(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	phone := r.URL.Query().Get("phone")
+	if phone == "" {
+		writeJSON(w, http.StatusOK, s.svc.Accounts())
+		return
+	}
+	find := s.svc.AccountsByPhone
+	//history=true ищет и по прежним номерам счетов
+	if r.URL.Query().Get("history") == "true" {
+		find = s.svc.AccountsByPhoneHistory
+	}
+	accounts, err := find(types.Phone(phone))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, accounts)
+}
+
+func (s *Server) handleRegisterAccount(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request registerRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if request.Currency == "" {
+		request.Currency = types.DefaultCurrency
+	}
+	account, err := s.svc.RegisterAccountInCurrency(request.Phone, request.Currency)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, account)
+}
+
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, err := s.svc.FindAccountByID(accountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
+
+//handleAccountStatus возвращает обработчик, меняющий статус счёта через change
+func (s *Server) handleAccountStatus(change func(accountID int64) error) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		accountID, err := strconv.ParseInt(params["id"], 10, 64)
+		if err != nil {
+			writeServiceError(w, wallet.ErrAccountNotFound)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		err = change(accountID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		account, err := s.svc.FindAccountByID(accountID)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, account)
+	}
+}
+
+func (s *Server) handleCloseAccount(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request closeRequest
+	if !decodeOptional(w, r, &request) {
+		return
+	}
+	s.handleAccountStatus(func(accountID int64) error {
+		return s.svc.CloseAuthorized(accountID, request.PIN, request.PayoutAccountID)
+	})(w, r, params)
+}
+
+func (s *Server) handleChangePhone(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+	var request phoneRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, err := s.svc.ChangePhoneAuthorized(accountID, request.PIN, request.Phone)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
+
+func (s *Server) handlePhoneHistory(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes, err := s.svc.PhoneHistory(accountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+func (s *Server) handleSetPIN(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+	var request setPINRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = s.svc.SetPIN(accountID, request.CurrentPIN, request.PIN)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleVerifyPIN(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+	var request verifyPINRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = s.svc.VerifyPIN(accountID, request.PIN)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profile, err := s.svc.Profile(accountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+func (s *Server) handleSetProfile(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+	var request types.Profile
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profile, err := s.svc.SetProfile(accountID, request)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+func (s *Server) handleUpgradeRequests(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests, err := s.svc.UpgradeRequests(accountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, requests)
+}
+
+func (s *Server) handleRequestUpgrade(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+	var request upgradeRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upgrade, err := s.svc.RequestUpgrade(accountID, request.Tier)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, upgrade)
+}
+
+func (s *Server) handleApproveUpgrade(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upgrade, err := s.svc.ApproveUpgrade(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, upgrade)
+}
+
+func (s *Server) handleRejectUpgrade(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request rejectUpgradeRequest
+	if !decodeOptional(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upgrade, err := s.svc.RejectUpgrade(params["id"], request.Reason)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, upgrade)
+}
+
+func (s *Server) handleDeposit(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+	var request depositRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if request.Currency != "" {
+		err = s.svc.CheckCurrency(accountID, request.Currency)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
+	err = s.svc.DepositIdempotent(r.Header.Get(idempotencyKeyHeader), accountID, request.Amount)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	account, err := s.svc.FindAccountByID(accountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
+
+func (s *Server) handlePay(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request payRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if request.Currency != "" {
+		err := s.svc.CheckCurrency(request.AccountID, request.Currency)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
+	//со счёта с ПИН-кодом можно платить только после его проверки
+	if request.PIN != "" || s.svc.HasPIN(request.AccountID) {
+		err := s.svc.VerifyPIN(request.AccountID, request.PIN)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
+	payment, err := s.svc.PayIdempotent(r.Header.Get(idempotencyKeyHeader), request.AccountID, request.Amount, request.Category)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, payment)
+}
+
+func (s *Server) handlePayment(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, err := s.svc.FindPaymentByID(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, payment)
+}
+
+func (s *Server) handleReject(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.svc.Reject(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	payment, err := s.svc.FindPaymentByID(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, payment)
+}
+
+func (s *Server) handleRepeat(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	//тело нужно только для ПИН-кода, если он задан у счёта
+	var request verifyPINRequest
+	if !decodeOptional(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, err := s.svc.RepeatAuthorized(params["id"], request.PIN)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, payment)
+}
+
+func (s *Server) handleRefunds(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refunds, err := s.svc.FindRefundsByPaymentID(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, refunds)
+}
+
+func (s *Server) handleConfirmPayment(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request confirmRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, err := s.svc.ConfirmPayment(params["id"], request.Code)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, payment)
+}
+
+func (s *Server) handleRefund(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request refundRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refund, err := s.svc.Refund(params["id"], request.Amount)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, refund)
+}
+
+func (s *Server) handleFavoritePayment(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request favoriteRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	favorite, err := s.svc.FavoritePayment(request.PaymentID, request.Name)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, favorite)
+}
+
+func (s *Server) handleFavorite(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	favorite, err := s.svc.FindFavoriteByID(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, favorite)
+}
+
+func (s *Server) handleAccountFavorites(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	favorites, err := s.svc.FavoritesByAccount(accountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, favorites)
+}
+
+func (s *Server) handleLimits(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limits, err := s.svc.Limits(accountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, limits)
+}
+
+func (s *Server) handleSetLimits(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+	var request types.Limits
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = s.svc.SetLimits(accountID, request)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	limits, err := s.svc.Limits(accountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, limits)
+}
+
+func (s *Server) handleCategoryReport(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	//по умолчанию - с начала текущего месяца до текущего момента по часам сервиса
+	now := s.svc.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := now
+	for name, value := range map[string]*time.Time{"from": &from, "to": &to} {
+		query := r.URL.Query().Get(name)
+		if query == "" {
+			continue
+		}
+		*value, err = time.Parse(time.RFC3339, query)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errBadRequest.Error()+": "+name+": "+err.Error())
+			return
+		}
+	}
+
+	report, err := s.svc.CategoryReport(accountID, from, to)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) handleAccountConversions(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conversions, err := s.svc.Conversions(accountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, conversions)
+}
+
+func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request convertRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conversion, err := s.svc.ConvertAuthorized(request.FromAccountID, request.ToAccountID, request.PIN, request.Amount)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, conversion)
+}
+
+func (s *Server) handleAccountEnvelopes(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	envelopes, err := s.svc.EnvelopesByAccount(accountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, envelopes)
+}
+
+func (s *Server) handleCreateEnvelope(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+	var request envelopeRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	envelope, err := s.svc.CreateEnvelope(accountID, request.Name)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, envelope)
+}
+
+func (s *Server) handleEnvelope(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	envelope, err := s.svc.FindEnvelopeByID(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, envelope)
+}
+
+func (s *Server) handleRemoveEnvelope(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.svc.RemoveEnvelope(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleMoveToEnvelope(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.moveEnvelope(w, r, params["id"], s.svc.MoveToEnvelope)
+}
+
+func (s *Server) handleMoveFromEnvelope(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.moveEnvelope(w, r, params["id"], s.svc.MoveFromEnvelope)
+}
+
+//moveEnvelope переносит сумму из запроса и отвечает конвертом с новым балансом
+func (s *Server) moveEnvelope(w http.ResponseWriter, r *http.Request, envelopeID string, move func(string, types.Money) error) {
+	var request depositRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	envelope, err := s.svc.FindEnvelopeByID(envelopeID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if request.Currency != "" {
+		err = s.svc.CheckCurrency(envelope.AccountID, request.Currency)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
+	err = move(envelopeID, request.Amount)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, envelope)
+}
+
+func (s *Server) handlePayFromEnvelope(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request envelopePayRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, err := s.svc.PayFromEnvelopeAuthorized(params["id"], request.PIN, request.Amount, request.Category)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, payment)
+}
+
+func (s *Server) handleCreateGoal(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request goalRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	goal, err := s.svc.CreateGoal(params["id"], request.Target, request.Deadline)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, goal)
+}
+
+func (s *Server) handleGoal(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	goal, err := s.svc.FindGoalByID(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, goal)
+}
+
+func (s *Server) handleGoalProgress(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	progress, err := s.svc.GoalProgress(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, progress)
+}
+
+func (s *Server) handleSetGoalRoundUp(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request roundUpRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.svc.SetGoalRoundUp(params["id"], request.Unit)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	goal, err := s.svc.FindGoalByID(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, goal)
+}
+
+func (s *Server) handleCategories(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, s.svc.Categories())
+}
+
+func (s *Server) handleRegisterCategory(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request types.Category
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category, err := s.svc.RegisterCategory(request)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, category)
+}
+
+func (s *Server) handleCategory(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category, err := s.svc.FindCategory(types.PaymentCategory(params["code"]))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, category)
+}
+
+func (s *Server) handleBudgets(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	budgets, err := s.svc.Budgets(accountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, budgets)
+}
+
+func (s *Server) handleSetBudget(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+	var request budgetRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	budget, err := s.svc.SetBudget(accountID, types.PaymentCategory(params["category"]), request.Amount)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, budget)
+}
+
+func (s *Server) handleRemoveBudget(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	accountID, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		writeServiceError(w, wallet.ErrAccountNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.svc.FindAccountByID(accountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	s.svc.RemoveBudget(accountID, types.PaymentCategory(params["category"]))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUpdateFavorite(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request updateFavoriteRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	favorite, err := s.svc.UpdateFavorite(params["id"], request.Name, request.Amount)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, favorite)
+}
+
+func (s *Server) handleRemoveFavorite(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.svc.RemoveFavorite(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleMoveFavorite(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request moveFavoriteRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.svc.MoveFavorite(params["id"], request.Position)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	favorite, err := s.svc.FindFavoriteByID(params["id"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, favorite)
+}
+
+func (s *Server) handleSetTemplate(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request templateRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	favorite, err := s.svc.SetTemplate(params["id"], request.VariableAmount, request.Fields)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, favorite)
+}
+
+func (s *Server) handlePayFromFavorite(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	//тело необязательно: без него платёж выполняется по значениям избранного
+	var request favoritePayRequest
+	if !decodeOptional(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, err := s.svc.PayFromTemplateAuthorized(params["id"], request.PIN, request.TemplateOverrides)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, payment)
+}
+
+//decode читает JSON из тела запроса; при ошибке сам отвечает клиенту
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errBadRequest.Error()+": "+err.Error())
+		return false
+	}
+	return true
+}
+
+//decodeOptional работает как decode, но допускает пустое тело запроса
+func decodeOptional(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, errBadRequest.Error()+": "+err.Error())
+		return false
+	}
+	return true
+}
+
+//statusCode сопоставляет ошибки сервиса с HTTP статусами
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, wallet.ErrAccountNotFound),
+		errors.Is(err, wallet.ErrPaymentNotFound),
+		errors.Is(err, wallet.ErrFavoriteNotFound),
+		errors.Is(err, wallet.ErrCategoryNotFound),
+		errors.Is(err, wallet.ErrEnvelopeNotFound),
+		errors.Is(err, wallet.ErrGoalNotFound),
+		errors.Is(err, wallet.ErrUpgradeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, wallet.ErrPhoneRegistered),
+		errors.Is(err, wallet.ErrPaymentRejected),
+		errors.Is(err, wallet.ErrFavoriteNameTaken),
+		errors.Is(err, wallet.ErrCategoryExists),
+		errors.Is(err, wallet.ErrEnvelopeNameTaken),
+		errors.Is(err, wallet.ErrAccountFrozen),
+		errors.Is(err, wallet.ErrAccountClosed),
+		errors.Is(err, wallet.ErrAccountNotEmpty),
+		errors.Is(err, wallet.ErrUpgradePending),
+		errors.Is(err, wallet.ErrUpgradeNotPending),
+		errors.Is(err, wallet.ErrPaymentNotPending),
+		errors.Is(err, wallet.ErrPaymentNotConfirmed),
+		errors.Is(err, wallet.ErrGoalExists):
+		return http.StatusConflict
+	case errors.Is(err, wallet.ErrAmountMustBePositive),
+		errors.Is(err, wallet.ErrFavoriteNameEmpty),
+		errors.Is(err, wallet.ErrInvalidTemplate),
+		errors.Is(err, wallet.ErrEnvelopeNameEmpty),
+		errors.Is(err, wallet.ErrUnsupportedCurrency),
+		errors.Is(err, wallet.ErrInvalidLimits),
+		errors.Is(err, wallet.ErrInvalidCategory),
+		errors.Is(err, wallet.ErrUnknownCategory),
+		errors.Is(err, wallet.ErrTemplateFieldUnknown),
+		errors.Is(err, wallet.ErrInvalidPhone),
+		errors.Is(err, wallet.ErrInvalidPayout),
+		errors.Is(err, wallet.ErrInvalidProfile),
+		errors.Is(err, wallet.ErrInvalidTier),
+		errors.Is(err, wallet.ErrInvalidPIN),
+		errors.Is(err, wallet.ErrUnsupportedCountry),
+		errors.Is(err, wallet.ErrInvalidSpread),
+		errors.Is(err, types.ErrInvalidMoney):
+		return http.StatusBadRequest
+	case errors.Is(err, wallet.ErrNotEnoughBalance),
+		errors.Is(err, wallet.ErrIdempotencyKeyReused),
+		errors.Is(err, wallet.ErrRefundExceedsPayment),
+		errors.Is(err, wallet.ErrTemplateAmountFixed),
+		errors.Is(err, wallet.ErrTemplateAmountRequired),
+		errors.Is(err, wallet.ErrTemplateFieldRequired),
+		errors.Is(err, wallet.ErrLimitExceeded),
+		errors.Is(err, wallet.ErrCurrencyMismatch),
+		errors.Is(err, wallet.ErrConversionNotAllowed),
+		errors.Is(err, wallet.ErrRateNotFound),
+		errors.Is(err, wallet.ErrProfileIncomplete),
+		errors.Is(err, wallet.ErrTierLimitExceeded),
+		errors.Is(err, wallet.ErrCashbackSpent),
+		errors.Is(err, wallet.ErrPINNotSet),
+		errors.Is(err, wallet.ErrCodeExpired),
+		errors.Is(err, wallet.ErrCodeAttemptsExceeded),
+		errors.Is(err, types.ErrMoneyOverflow):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, wallet.ErrWrongPIN),
+		errors.Is(err, wallet.ErrWrongCode):
+		return http.StatusForbidden
+	case errors.Is(err, wallet.ErrPINLocked):
+		return http.StatusLocked
+	//сервер не настроен для операции: клиент может повторить запрос позже
+	case errors.Is(err, wallet.ErrNoCodeNotifier),
+		errors.Is(err, wallet.ErrNoRateProvider):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func writeServiceError(w http.ResponseWriter, err error) {
+	status := statusCode(err)
+	if status == http.StatusInternalServerError {
+		//подробности внутренних ошибок клиенту не отдаём
+		log.Print(err)
+		writeError(w, status, http.StatusText(status))
+		return
+	}
+	writeError(w, status, err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Print(err)
+	}
+}
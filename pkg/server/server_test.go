@@ -0,0 +1,812 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+	"github.com/Behzod01/wallet/pkg/wallet"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(New(&wallet.Service{}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func do(t *testing.T, ts *httptest.Server, method string, path string, body string, v interface{}) int {
+	t.Helper()
+	request, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := ts.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent && response.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("%s %s: wrong content type %q", method, path, response.Header.Get("Content-Type"))
+	}
+	if v != nil {
+		err = json.NewDecoder(response.Body).Decode(v)
+		if err != nil {
+			t.Fatalf("%s %s: can't decode response, error = %v", method, path, err)
+		}
+	}
+	return response.StatusCode
+}
+
+func TestServer_paymentFlow(t *testing.T) {
+	ts := newTestServer(t)
+
+	var account types.Account
+	status := do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, &account)
+	if status != http.StatusCreated || account.ID != 1 {
+		t.Fatalf("register: status = %d, account = %v", status, account)
+	}
+
+	status = do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, &account)
+	if status != http.StatusOK || account.Balance != 1000 {
+		t.Fatalf("deposit: status = %d, account = %v", status, account)
+	}
+
+	var payment types.Payment
+	status = do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":300,"category":"auto"}`, &payment)
+	if status != http.StatusCreated || payment.Amount != 300 {
+		t.Fatalf("pay: status = %d, payment = %v", status, payment)
+	}
+
+	var repeated types.Payment
+	status = do(t, ts, http.MethodPost, "/payments/"+payment.ID+"/repeat", "", &repeated)
+	if status != http.StatusCreated || repeated.ID == payment.ID {
+		t.Fatalf("repeat: status = %d, payment = %v", status, repeated)
+	}
+
+	status = do(t, ts, http.MethodPost, "/payments/"+payment.ID+"/reject", "", &payment)
+	if status != http.StatusOK || payment.Status != types.PaymentStatusFail {
+		t.Fatalf("reject: status = %d, payment = %v", status, payment)
+	}
+
+	var favorite types.Favorite
+	status = do(t, ts, http.MethodPost, "/favorites", `{"paymentId":"`+repeated.ID+`","name":"car"}`, &favorite)
+	if status != http.StatusCreated || favorite.Name != "car" {
+		t.Fatalf("favorite: status = %d, favorite = %v", status, favorite)
+	}
+
+	status = do(t, ts, http.MethodGet, "/favorites/"+favorite.ID, "", &favorite)
+	if status != http.StatusOK {
+		t.Fatalf("get favorite: status = %d", status)
+	}
+
+	status = do(t, ts, http.MethodPost, "/favorites/"+favorite.ID+"/payments", "", &payment)
+	if status != http.StatusCreated || payment.Amount != 300 {
+		t.Fatalf("pay from favorite: status = %d, payment = %v", status, payment)
+	}
+
+	status = do(t, ts, http.MethodGet, "/accounts/1", "", &account)
+	if status != http.StatusOK || account.Balance != 400 {
+		t.Fatalf("get account: status = %d, account = %v", status, account)
+	}
+
+	var accounts []types.Account
+	status = do(t, ts, http.MethodGet, "/accounts", "", &accounts)
+	if status != http.StatusOK || len(accounts) != 1 {
+		t.Fatalf("list accounts: status = %d, accounts = %v", status, accounts)
+	}
+}
+
+func TestServer_errors(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, http.StatusConflict},
+		{http.MethodPost, "/accounts", `{"phone":`, http.StatusBadRequest},
+		{http.MethodGet, "/accounts/2", "", http.StatusNotFound},
+		{http.MethodGet, "/accounts/abc", "", http.StatusNotFound},
+		{http.MethodPost, "/accounts/1/deposits", `{"amount":-1}`, http.StatusBadRequest},
+		{http.MethodPost, "/payments", `{"accountId":1,"amount":100,"category":"auto"}`, http.StatusUnprocessableEntity},
+		{http.MethodGet, "/payments/unknown", "", http.StatusNotFound},
+		{http.MethodPost, "/payments/unknown/repeat", "", http.StatusNotFound},
+		{http.MethodPost, "/favorites/unknown/payments", "", http.StatusNotFound},
+		{http.MethodDelete, "/accounts/1", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/unknown", "", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		var response errorResponse
+		status := do(t, ts, test.method, test.path, test.body, &response)
+		if status != test.status {
+			t.Errorf("%s %s: status = %d, want %d", test.method, test.path, status, test.status)
+		}
+		if response.Error == "" {
+			t.Errorf("%s %s: error body is empty", test.method, test.path)
+		}
+	}
+}
+
+func TestServer_unavailable(t *testing.T) {
+	svc := &wallet.Service{}
+	svc.SetConfirmationPolicy(types.ConfirmationPolicy{Threshold: 500})
+	ts := httptest.NewServer(New(svc))
+	t.Cleanup(ts.Close)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001","currency":"USD"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+
+	var response errorResponse
+	status := do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":600,"category":"cafe"}`, &response)
+	if status != http.StatusServiceUnavailable || response.Error != wallet.ErrNoCodeNotifier.Error() {
+		t.Errorf("pay without code notifier: status = %d, response = %v", status, response)
+	}
+	status = do(t, ts, http.MethodPost, "/conversions", `{"fromAccountId":1,"toAccountId":2,"amount":100}`, &response)
+	if status != http.StatusServiceUnavailable || response.Error != wallet.ErrNoRateProvider.Error() {
+		t.Errorf("convert without rate provider: status = %d, response = %v", status, response)
+	}
+}
+
+func TestServer_statusCode(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{types.ErrMoneyOverflow, http.StatusUnprocessableEntity},
+		{fmt.Errorf("deposit: %w", types.ErrMoneyOverflow), http.StatusUnprocessableEntity},
+		{types.ErrInvalidMoney, http.StatusBadRequest},
+		{wallet.ErrInvalidSpread, http.StatusBadRequest},
+		{wallet.ErrNoCodeNotifier, http.StatusServiceUnavailable},
+		{wallet.ErrNoRateProvider, http.StatusServiceUnavailable},
+		{errors.New("storage is broken"), http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		status := statusCode(test.err)
+		if status != test.status {
+			t.Errorf("statusCode(%v) = %d, want %d", test.err, status, test.status)
+		}
+	}
+}
+
+func TestServer_idempotencyKey(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+
+	deposit := func() int {
+		request, _ := http.NewRequest(http.MethodPost, ts.URL+"/accounts/1/deposits", strings.NewReader(`{"amount":1000}`))
+		request.Header.Set("Idempotency-Key", "deposit-1")
+		response, err := ts.Client().Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+	pay := func(body string) (int, types.Payment) {
+		request, _ := http.NewRequest(http.MethodPost, ts.URL+"/payments", strings.NewReader(body))
+		request.Header.Set("Idempotency-Key", "pay-1")
+		response, err := ts.Client().Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		var payment types.Payment
+		json.NewDecoder(response.Body).Decode(&payment)
+		return response.StatusCode, payment
+	}
+
+	deposit()
+	deposit()
+	first, payment := pay(`{"accountId":1,"amount":300,"category":"auto"}`)
+	second, repeated := pay(`{"accountId":1,"amount":300,"category":"auto"}`)
+	if first != http.StatusCreated || second != http.StatusCreated || payment.ID != repeated.ID {
+		t.Errorf("repeated pay: statuses = %d, %d, ids = %s, %s", first, second, payment.ID, repeated.ID)
+	}
+	status, _ := pay(`{"accountId":1,"amount":400,"category":"auto"}`)
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("reused key: status = %d, want %d", status, http.StatusUnprocessableEntity)
+	}
+
+	var account types.Account
+	do(t, ts, http.MethodGet, "/accounts/1", "", &account)
+	if account.Balance != 700 {
+		t.Errorf("balance = %d, want 700", account.Balance)
+	}
+}
+
+func TestServer_refunds(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+	var payment types.Payment
+	do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":300,"category":"auto"}`, &payment)
+
+	var refund types.Refund
+	status := do(t, ts, http.MethodPost, "/payments/"+payment.ID+"/refunds", `{"amount":100}`, &refund)
+	if status != http.StatusCreated || refund.Amount != 100 {
+		t.Fatalf("refund: status = %d, refund = %v", status, refund)
+	}
+	status = do(t, ts, http.MethodPost, "/payments/"+payment.ID+"/refunds", `{"amount":201}`, nil)
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("over refund: status = %d", status)
+	}
+
+	var refunds []types.Refund
+	status = do(t, ts, http.MethodGet, "/payments/"+payment.ID+"/refunds", "", &refunds)
+	if status != http.StatusOK || len(refunds) != 1 {
+		t.Errorf("list refunds: status = %d, refunds = %v", status, refunds)
+	}
+	do(t, ts, http.MethodGet, "/payments/"+payment.ID, "", &payment)
+	if payment.Refunded != 100 {
+		t.Errorf("payment: refunded = %d", payment.Refunded)
+	}
+
+	do(t, ts, http.MethodPost, "/payments/"+payment.ID+"/reject", "", nil)
+	status = do(t, ts, http.MethodPost, "/payments/"+payment.ID+"/reject", "", nil)
+	if status != http.StatusConflict {
+		t.Errorf("second reject: status = %d", status)
+	}
+}
+
+func TestServer_favorites(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+	var payment types.Payment
+	do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":300,"category":"auto"}`, &payment)
+	var first, second types.Favorite
+	do(t, ts, http.MethodPost, "/favorites", `{"paymentId":"`+payment.ID+`","name":"car"}`, &first)
+	do(t, ts, http.MethodPost, "/favorites", `{"paymentId":"`+payment.ID+`","name":"taxi"}`, &second)
+
+	status := do(t, ts, http.MethodPost, "/favorites", `{"paymentId":"`+payment.ID+`","name":"Car"}`, nil)
+	if status != http.StatusConflict {
+		t.Errorf("duplicate name: status = %d", status)
+	}
+
+	status = do(t, ts, http.MethodPut, "/favorites/"+first.ID, `{"name":"auto","amount":500}`, &first)
+	if status != http.StatusOK || first.Name != "auto" || first.Amount != 500 {
+		t.Errorf("update: status = %d, favorite = %v", status, first)
+	}
+
+	status = do(t, ts, http.MethodPost, "/favorites/"+second.ID+"/position", `{"position":0}`, &second)
+	if status != http.StatusOK || second.Position != 0 {
+		t.Errorf("move: status = %d, favorite = %v", status, second)
+	}
+
+	var favorites []types.Favorite
+	status = do(t, ts, http.MethodGet, "/accounts/1/favorites", "", &favorites)
+	if status != http.StatusOK || len(favorites) != 2 || favorites[0].ID != second.ID {
+		t.Errorf("list: status = %d, favorites = %v", status, favorites)
+	}
+
+	status = do(t, ts, http.MethodDelete, "/favorites/"+second.ID, "", nil)
+	if status != http.StatusNoContent {
+		t.Errorf("delete: status = %d", status)
+	}
+	status = do(t, ts, http.MethodGet, "/favorites/"+second.ID, "", nil)
+	if status != http.StatusNotFound {
+		t.Errorf("get deleted: status = %d", status)
+	}
+}
+
+func TestServer_templates(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+	var payment types.Payment
+	do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":300,"category":"utilities"}`, &payment)
+	var favorite types.Favorite
+	do(t, ts, http.MethodPost, "/favorites", `{"paymentId":"`+payment.ID+`","name":"water"}`, &favorite)
+
+	status := do(t, ts, http.MethodPut, "/favorites/"+favorite.ID+"/template", `{"variableAmount":true,"fields":[{"name":"meter","required":true}]}`, &favorite)
+	if status != http.StatusOK || !favorite.VariableAmount || len(favorite.Fields) != 1 {
+		t.Fatalf("template: status = %d, favorite = %v", status, favorite)
+	}
+
+	status = do(t, ts, http.MethodPost, "/favorites/"+favorite.ID+"/payments", "", nil)
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("pay without amount: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPost, "/favorites/"+favorite.ID+"/payments", `{"amount":200,"fields":{"flat":"1"}}`, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("pay with unknown field: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPost, "/favorites/"+favorite.ID+"/payments", `{"amount":200,"fields":{"meter":"7"}}`, &payment)
+	if status != http.StatusCreated || payment.Amount != 200 || payment.Details["meter"] != "7" {
+		t.Errorf("pay: status = %d, payment = %v", status, payment)
+	}
+}
+
+func TestServer_limits(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+
+	var limits types.Limits
+	status := do(t, ts, http.MethodPut, "/accounts/1/limits", `{"daily":500,"categories":{"cafe":100}}`, &limits)
+	if status != http.StatusOK || limits.Daily != 500 || limits.Categories["cafe"] != 100 {
+		t.Fatalf("set limits: status = %d, limits = %v", status, limits)
+	}
+	status = do(t, ts, http.MethodPut, "/accounts/1/limits", `{"daily":-1}`, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("negative limit: status = %d", status)
+	}
+
+	do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":400,"category":"auto"}`, nil)
+	var response struct {
+		Error string `json:"error"`
+	}
+	status = do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":200,"category":"auto"}`, &response)
+	if status != http.StatusUnprocessableEntity || !strings.Contains(response.Error, "remaining 100") {
+		t.Errorf("over limit: status = %d, error = %q", status, response.Error)
+	}
+}
+
+func TestServer_budgets(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+
+	var budget types.Budget
+	status := do(t, ts, http.MethodPut, "/accounts/1/budgets/cafe", `{"amount":500}`, &budget)
+	if status != http.StatusOK || budget.Amount != 500 {
+		t.Fatalf("set budget: status = %d, budget = %v", status, budget)
+	}
+	do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":200,"category":"cafe"}`, nil)
+
+	var statuses []types.BudgetStatus
+	status = do(t, ts, http.MethodGet, "/accounts/1/budgets", "", &statuses)
+	if status != http.StatusOK || len(statuses) != 1 || statuses[0].Spent != 200 || statuses[0].Remaining != 300 {
+		t.Errorf("list budgets: status = %d, statuses = %v", status, statuses)
+	}
+
+	status = do(t, ts, http.MethodDelete, "/accounts/1/budgets/cafe", "", nil)
+	if status != http.StatusNoContent {
+		t.Errorf("delete budget: status = %d", status)
+	}
+	status = do(t, ts, http.MethodDelete, "/accounts/2/budgets/cafe", "", nil)
+	if status != http.StatusNotFound {
+		t.Errorf("delete budget of unknown account: status = %d", status)
+	}
+}
+
+func TestServer_categories(t *testing.T) {
+	svc := &wallet.Service{}
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	svc.SetClock(func() time.Time { return now })
+	ts := httptest.NewServer(New(svc))
+	t.Cleanup(ts.Close)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+
+	var category types.Category
+	status := do(t, ts, http.MethodPost, "/categories", `{"code":"food","names":{"en":"Food"}}`, &category)
+	if status != http.StatusCreated || category.Code != "food" {
+		t.Fatalf("register: status = %d, category = %v", status, category)
+	}
+	do(t, ts, http.MethodPost, "/categories", `{"code":"cafe","parent":"food","mcc":"5812"}`, nil)
+	status = do(t, ts, http.MethodPost, "/categories", `{"code":"Food"}`, nil)
+	if status != http.StatusConflict {
+		t.Errorf("duplicate: status = %d", status)
+	}
+	status = do(t, ts, http.MethodGet, "/categories/CAFE", "", &category)
+	if status != http.StatusOK || category.Parent != "food" {
+		t.Errorf("get: status = %d, category = %v", status, category)
+	}
+
+	status = do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":100,"category":"taxi"}`, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("unknown category: status = %d", status)
+	}
+	do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":100,"category":"cafe"}`, nil)
+
+	//период по умолчанию считается по часам сервиса, а не по системному времени
+	now = now.Add(time.Minute)
+	var report []types.CategoryTotal
+	status = do(t, ts, http.MethodGet, "/accounts/1/report", "", &report)
+	if status != http.StatusOK || len(report) != 2 || report[1].Category != "food" || report[1].Total != 100 {
+		t.Errorf("report: status = %d, report = %v", status, report)
+	}
+	status = do(t, ts, http.MethodGet, "/accounts/1/report?from=yesterday", "", nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("report with bad date: status = %d", status)
+	}
+}
+
+func TestServer_envelopes(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+
+	var envelope types.Envelope
+	status := do(t, ts, http.MethodPost, "/accounts/1/envelopes", `{"name":"vacation"}`, &envelope)
+	if status != http.StatusCreated || envelope.Name != "vacation" {
+		t.Fatalf("create: status = %d, envelope = %v", status, envelope)
+	}
+	status = do(t, ts, http.MethodPost, "/envelopes/"+envelope.ID+"/deposits", `{"amount":600}`, &envelope)
+	if status != http.StatusOK || envelope.Balance != 600 {
+		t.Errorf("move in: status = %d, envelope = %v", status, envelope)
+	}
+	status = do(t, ts, http.MethodPost, "/envelopes/"+envelope.ID+"/withdrawals", `{"amount":700}`, nil)
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("move out too much: status = %d", status)
+	}
+
+	var payment types.Payment
+	status = do(t, ts, http.MethodPost, "/envelopes/"+envelope.ID+"/payments", `{"amount":100,"category":"travel"}`, &payment)
+	if status != http.StatusCreated || payment.EnvelopeID != envelope.ID {
+		t.Errorf("pay: status = %d, payment = %v", status, payment)
+	}
+
+	var envelopes []types.Envelope
+	do(t, ts, http.MethodGet, "/accounts/1/envelopes", "", &envelopes)
+	if len(envelopes) != 1 || envelopes[0].Balance != 500 {
+		t.Errorf("list: envelopes = %v", envelopes)
+	}
+
+	status = do(t, ts, http.MethodDelete, "/envelopes/"+envelope.ID, "", nil)
+	if status != http.StatusNoContent {
+		t.Errorf("delete: status = %d", status)
+	}
+	var account types.Account
+	do(t, ts, http.MethodGet, "/accounts/1", "", &account)
+	if account.Balance != 900 {
+		t.Errorf("account after delete: balance = %d", account.Balance)
+	}
+}
+
+func TestServer_goals(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+	var envelope types.Envelope
+	do(t, ts, http.MethodPost, "/accounts/1/envelopes", `{"name":"bike"}`, &envelope)
+
+	var goal types.Goal
+	status := do(t, ts, http.MethodPost, "/envelopes/"+envelope.ID+"/goal", `{"target":100,"deadline":"2030-01-01T00:00:00Z"}`, &goal)
+	if status != http.StatusCreated || goal.Target != 100 {
+		t.Fatalf("create: status = %d, goal = %v", status, goal)
+	}
+	status = do(t, ts, http.MethodPost, "/envelopes/"+envelope.ID+"/goal", `{"target":50}`, nil)
+	if status != http.StatusConflict {
+		t.Errorf("second goal: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPut, "/goals/"+goal.ID+"/round-up", `{"unit":50}`, &goal)
+	if status != http.StatusOK || goal.RoundUp != 50 {
+		t.Errorf("round-up: status = %d, goal = %v", status, goal)
+	}
+
+	do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":120,"category":"cafe"}`, nil)
+	var progress types.GoalProgress
+	status = do(t, ts, http.MethodGet, "/goals/"+goal.ID+"/progress", "", &progress)
+	if status != http.StatusOK || progress.Saved != 30 || progress.Percent != 30 {
+		t.Errorf("progress: status = %d, progress = %v", status, progress)
+	}
+}
+
+func TestServer_currencies(t *testing.T) {
+	ts := newTestServer(t)
+	var account types.Account
+	status := do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001","currency":"USD"}`, &account)
+	if status != http.StatusCreated || account.Currency != types.USD {
+		t.Fatalf("register: status = %d, account = %v", status, account)
+	}
+	status = do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001","currency":"XXX"}`, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("unknown currency: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000,"currency":"TJS"}`, nil)
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("deposit in another currency: status = %d", status)
+	}
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000,"currency":"USD"}`, nil)
+	status = do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":100,"currency":"TJS","category":"cafe"}`, nil)
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("pay in another currency: status = %d", status)
+	}
+	var payment types.Payment
+	status = do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":100,"category":"cafe"}`, &payment)
+	if status != http.StatusCreated || payment.Currency != types.USD {
+		t.Errorf("pay: status = %d, payment = %v", status, payment)
+	}
+}
+
+func TestServer_conversions(t *testing.T) {
+	svc := &wallet.Service{}
+	rates := wallet.NewStaticRates()
+	rates.Set(types.USD, types.TJS, 10_000_000)
+	svc.SetRateProvider(rates)
+	ts := httptest.NewServer(New(svc))
+	t.Cleanup(ts.Close)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001","currency":"USD"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/2/deposits", `{"amount":1000}`, nil)
+
+	var conversion types.Conversion
+	status := do(t, ts, http.MethodPost, "/conversions", `{"fromAccountId":2,"toAccountId":1,"amount":100}`, &conversion)
+	if status != http.StatusCreated || conversion.Converted != 1000 || conversion.Rate != 10_000_000 {
+		t.Fatalf("convert: status = %d, conversion = %v", status, conversion)
+	}
+	status = do(t, ts, http.MethodPost, "/conversions", `{"fromAccountId":1,"toAccountId":2,"amount":100}`, nil)
+	if status != http.StatusCreated {
+		t.Errorf("inverse convert: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPost, "/conversions", `{"fromAccountId":1,"toAccountId":1,"amount":100}`, nil)
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("same account: status = %d", status)
+	}
+
+	var conversions []types.Conversion
+	status = do(t, ts, http.MethodGet, "/accounts/1/conversions", "", &conversions)
+	if status != http.StatusOK || len(conversions) != 2 {
+		t.Errorf("list: status = %d, conversions = %v", status, conversions)
+	}
+}
+
+func TestServer_phones(t *testing.T) {
+	ts := newTestServer(t)
+	var account types.Account
+	status := do(t, ts, http.MethodPost, "/accounts", `{"phone":"992 000 00 00 01"}`, &account)
+	if status != http.StatusCreated || account.Phone != "+992000000001" {
+		t.Fatalf("register: status = %d, account = %v", status, account)
+	}
+	status = do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	if status != http.StatusConflict {
+		t.Errorf("same phone in another format: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPost, "/accounts", `{"phone":"12345"}`, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("invalid phone: status = %d", status)
+	}
+
+	var accounts []types.Account
+	status = do(t, ts, http.MethodGet, "/accounts?phone=000-00-00-01", "", &accounts)
+	if status != http.StatusOK || len(accounts) != 1 || accounts[0].ID != account.ID {
+		t.Errorf("find by phone: status = %d, accounts = %v", status, accounts)
+	}
+	status = do(t, ts, http.MethodGet, "/accounts?phone=abc", "", nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("find by invalid phone: status = %d", status)
+	}
+}
+
+func TestServer_accountStatus(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000002"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+
+	var account types.Account
+	status := do(t, ts, http.MethodPost, "/accounts/1/freeze", "", &account)
+	if status != http.StatusOK || account.Status != types.AccountStatusFrozen {
+		t.Fatalf("freeze: status = %d, account = %v", status, account)
+	}
+	status = do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":100,"category":"cafe"}`, nil)
+	if status != http.StatusConflict {
+		t.Errorf("pay from frozen account: status = %d", status)
+	}
+	do(t, ts, http.MethodPost, "/accounts/1/unfreeze", "", nil)
+
+	status = do(t, ts, http.MethodPost, "/accounts/1/close", "", nil)
+	if status != http.StatusConflict {
+		t.Errorf("close with balance: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPost, "/accounts/1/close", `{"payoutAccountId":2}`, &account)
+	if status != http.StatusOK || account.Status != types.AccountStatusClosed {
+		t.Errorf("close: status = %d, account = %v", status, account)
+	}
+	status = do(t, ts, http.MethodGet, "/accounts/2", "", &account)
+	if status != http.StatusOK || account.Balance != 1000 {
+		t.Errorf("payout: status = %d, account = %v", status, account)
+	}
+	status = do(t, ts, http.MethodPost, "/accounts/1/reopen", "", &account)
+	if status != http.StatusOK || account.Status != types.AccountStatusActive {
+		t.Errorf("reopen: status = %d, account = %v", status, account)
+	}
+}
+
+func TestServer_changePhone(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+
+	var account types.Account
+	status := do(t, ts, http.MethodPut, "/accounts/1/phone", `{"phone":"000000002"}`, &account)
+	if status != http.StatusOK || account.Phone != "+992000000002" {
+		t.Fatalf("change phone: status = %d, account = %v", status, account)
+	}
+	var changes []types.PhoneChange
+	status = do(t, ts, http.MethodGet, "/accounts/1/phones", "", &changes)
+	if status != http.StatusOK || len(changes) != 1 || changes[0].Previous != "+992000000001" {
+		t.Errorf("history: status = %d, changes = %v", status, changes)
+	}
+
+	var accounts []types.Account
+	do(t, ts, http.MethodGet, "/accounts?phone=%2B992000000001", "", &accounts)
+	if len(accounts) != 0 {
+		t.Errorf("find by previous phone without history: accounts = %v", accounts)
+	}
+	do(t, ts, http.MethodGet, "/accounts?phone=%2B992000000001&history=true", "", &accounts)
+	if len(accounts) != 1 || accounts[0].ID != 1 {
+		t.Errorf("find by previous phone: accounts = %v", accounts)
+	}
+}
+
+func TestServer_kyc(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+
+	status := do(t, ts, http.MethodPost, "/accounts/1/upgrades", `{"tier":"BASIC"}`, nil)
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("upgrade without profile: status = %d", status)
+	}
+	var profile types.Profile
+	status = do(t, ts, http.MethodPut, "/accounts/1/profile", `{"name":"Behzod","birthDate":"1990-05-01T00:00:00Z"}`, &profile)
+	if status != http.StatusOK || profile.Name != "Behzod" {
+		t.Fatalf("set profile: status = %d, profile = %v", status, profile)
+	}
+	status = do(t, ts, http.MethodPut, "/accounts/1/profile", `{"name":""}`, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("invalid profile: status = %d", status)
+	}
+
+	var request types.UpgradeRequest
+	status = do(t, ts, http.MethodPost, "/accounts/1/upgrades", `{"tier":"BASIC"}`, &request)
+	if status != http.StatusCreated || request.Status != types.UpgradeStatusPending {
+		t.Fatalf("request upgrade: status = %d, request = %v", status, request)
+	}
+	status = do(t, ts, http.MethodPost, "/upgrades/"+request.ID+"/approve", "", &request)
+	if status != http.StatusOK || request.Status != types.UpgradeStatusApproved {
+		t.Errorf("approve: status = %d, request = %v", status, request)
+	}
+	status = do(t, ts, http.MethodPost, "/upgrades/"+request.ID+"/reject", "", nil)
+	if status != http.StatusConflict {
+		t.Errorf("reject decided request: status = %d", status)
+	}
+	var account types.Account
+	do(t, ts, http.MethodGet, "/accounts/1", "", &account)
+	if account.Tier != types.KYCTierBasic {
+		t.Errorf("tier after approve = %v", account.Tier)
+	}
+}
+
+func TestServer_pin(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+
+	status := do(t, ts, http.MethodPut, "/accounts/1/pin", `{"pin":"12"}`, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("invalid pin: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPut, "/accounts/1/pin", `{"pin":"1234"}`, nil)
+	if status != http.StatusNoContent {
+		t.Fatalf("set pin: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPost, "/accounts/1/pin/verify", `{"pin":"1234"}`, nil)
+	if status != http.StatusNoContent {
+		t.Errorf("verify pin: status = %d", status)
+	}
+
+	status = do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":100,"category":"cafe"}`, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("pay without pin: status = %d", status)
+	}
+	var payment types.Payment
+	status = do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":100,"category":"cafe","pin":"1234"}`, &payment)
+	if status != http.StatusCreated {
+		t.Errorf("pay with pin: status = %d", status)
+	}
+
+	//остальные списания и смена телефона тоже требуют ПИН-код
+	status = do(t, ts, http.MethodPost, "/payments/"+payment.ID+"/repeat", "", nil)
+	if status != http.StatusForbidden {
+		t.Errorf("repeat without pin: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPost, "/payments/"+payment.ID+"/repeat", `{"pin":"1234"}`, nil)
+	if status != http.StatusCreated {
+		t.Errorf("repeat with pin: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPost, "/accounts/1/close", "", nil)
+	if status != http.StatusForbidden {
+		t.Errorf("close without pin: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPut, "/accounts/1/phone", `{"phone":"+992000000002"}`, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("change phone without pin: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPost, "/conversions", `{"fromAccountId":1,"toAccountId":2,"amount":100,"pin":"1234"}`, nil)
+	if status != http.StatusNotFound {
+		t.Errorf("convert with pin: status = %d", status)
+	}
+
+	do(t, ts, http.MethodPost, "/accounts/1/pin/verify", `{"pin":"0000"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/pin/verify", `{"pin":"0000"}`, nil)
+	status = do(t, ts, http.MethodPost, "/accounts/1/pin/verify", `{"pin":"0000"}`, nil)
+	if status != http.StatusLocked {
+		t.Errorf("locked pin: status = %d", status)
+	}
+}
+
+func TestServer_confirmation(t *testing.T) {
+	svc := &wallet.Service{}
+	svc.SetConfirmationPolicy(types.ConfirmationPolicy{Threshold: 500})
+	var code string
+	svc.SetCodeNotifier(func(account *types.Account, payment *types.Payment, sent string) error {
+		code = sent
+		return nil
+	})
+	ts := httptest.NewServer(New(svc))
+	t.Cleanup(ts.Close)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+
+	var payment types.Payment
+	status := do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":600,"category":"cafe"}`, &payment)
+	if status != http.StatusCreated || payment.Status != types.PaymentStatusPendingConfirmation || payment.Confirmation == nil {
+		t.Fatalf("pay: status = %d, payment = %v", status, payment)
+	}
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	status = do(t, ts, http.MethodPost, "/payments/"+payment.ID+"/confirm", `{"code":"`+wrong+`"}`, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("wrong code: status = %d", status)
+	}
+	status = do(t, ts, http.MethodPost, "/payments/"+payment.ID+"/confirm", `{"code":"`+code+`"}`, &payment)
+	if status != http.StatusOK || payment.Status != types.PaymentStatusInProgress {
+		t.Errorf("confirm: status = %d, payment = %v", status, payment)
+	}
+	status = do(t, ts, http.MethodPost, "/payments/"+payment.ID+"/confirm", `{"code":"`+code+`"}`, nil)
+	if status != http.StatusConflict {
+		t.Errorf("confirm twice: status = %d", status)
+	}
+}
+
+func TestServer_RunScheduler(t *testing.T) {
+	svc := &wallet.Service{}
+	srv := New(svc)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	do(t, ts, http.MethodPost, "/accounts", `{"phone":"+992000000001"}`, nil)
+	do(t, ts, http.MethodPost, "/accounts/1/deposits", `{"amount":1000}`, nil)
+	var payment types.Payment
+	do(t, ts, http.MethodPost, "/payments", `{"accountId":1,"amount":100,"category":"mobile"}`, &payment)
+	var favorite types.Favorite
+	do(t, ts, http.MethodPost, "/favorites", `{"paymentId":"`+payment.ID+`","name":"mobile"}`, &favorite)
+	_, err := svc.SchedulePayment(favorite.ID, types.ScheduleRule{Kind: types.ScheduleOnce, Start: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		srv.RunScheduler(ctx, time.Millisecond)
+		close(done)
+	}()
+	var account types.Account
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		do(t, ts, http.MethodGet, "/accounts/1", "", &account)
+		if account.Balance == 800 {
+			break
+		}
+	}
+	cancel()
+	<-done
+	if account.Balance != 800 {
+		t.Errorf("scheduled payment must run, balance = %d", account.Balance)
+	}
+}
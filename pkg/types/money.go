@@ -0,0 +1,203 @@
+package types
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var ErrMoneyOverflow = errors.New("money overflow")
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+//Пределы Money
+const (
+	MaxMoney Money = math.MaxInt64
+	MinMoney Money = math.MinInt64
+)
+
+//Add возвращает m + other или ErrMoneyOverflow
+func (m Money) Add(other Money) (Money, error) {
+	if (other > 0 && m > MaxMoney-other) || (other < 0 && m < MinMoney-other) {
+		return 0, ErrMoneyOverflow
+	}
+	return m + other, nil
+}
+
+//Sub возвращает m - other или ErrMoneyOverflow
+func (m Money) Sub(other Money) (Money, error) {
+	if (other < 0 && m > MaxMoney+other) || (other > 0 && m < MinMoney+other) {
+		return 0, ErrMoneyOverflow
+	}
+	return m - other, nil
+}
+
+//Mul возвращает m * factor или ErrMoneyOverflow
+func (m Money) Mul(factor int64) (Money, error) {
+	result := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(factor))
+	if !result.IsInt64() {
+		return 0, ErrMoneyOverflow
+	}
+	return Money(result.Int64()), nil
+}
+
+//MulDiv возвращает m * numerator / denominator с округлением к нулю. Произведение
+//считается без переполнения, поэтому доля суммы, например m * part / whole, всегда точна
+func (m Money) MulDiv(numerator int64, denominator int64) (Money, error) {
+	if denominator == 0 {
+		return 0, ErrInvalidMoney
+	}
+	result := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(numerator))
+	result.Quo(result, big.NewInt(denominator))
+	if !result.IsInt64() {
+		return 0, ErrMoneyOverflow
+	}
+	return Money(result.Int64()), nil
+}
+
+//Percent возвращает долю суммы в базисных пунктах (150 - это 1,5%),
+//округляя половину минимальной единицы до чётного (банковское округление)
+func (m Money) Percent(basisPoints int64) (Money, error) {
+	product := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(basisPoints))
+	quotient, remainder := new(big.Int).QuoRem(product, big.NewInt(10_000), new(big.Int))
+
+	//сравниваем удвоенный остаток с делителем, чтобы понять, больше ли он половины
+	twice := new(big.Int).Abs(remainder)
+	twice.Mul(twice, big.NewInt(2))
+	cmp := twice.Cmp(big.NewInt(10_000))
+	if cmp > 0 || (cmp == 0 && quotient.Bit(0) == 1) {
+		if remainder.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	if !quotient.IsInt64() {
+		return 0, ErrMoneyOverflow
+	}
+	return Money(quotient.Int64()), nil
+}
+
+//Allocate делит сумму на parts частей так, что их сумма равна исходной:
+//остаток по одной минимальной единице достаётся первым частям
+func (m Money) Allocate(parts int) ([]Money, error) {
+	if parts <= 0 {
+		return nil, ErrInvalidMoney
+	}
+	share := m / Money(parts)
+	remainder := m % Money(parts)
+	unit := Money(1)
+	if remainder < 0 {
+		remainder = -remainder
+		unit = -1
+	}
+
+	result := make([]Money, parts)
+	for i := range result {
+		result[i] = share
+		if Money(i) < remainder {
+			result[i] += unit
+		}
+	}
+	return result, nil
+}
+
+//Format форматирует сумму для показа: "1 234,56 TJS".
+//Для неизвестной валюты используются два знака после запятой
+func (m Money) Format(currency Currency) string {
+	exponent, ok := currency.Exponent()
+	if !ok {
+		exponent = 2
+	}
+
+	//модуль через uint64, чтобы не переполниться на MinMoney
+	abs := uint64(m)
+	if m < 0 {
+		abs = -abs
+	}
+	digits := strconv.FormatUint(abs, 10)
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	whole := digits[:len(digits)-exponent]
+	fraction := digits[len(digits)-exponent:]
+
+	var builder strings.Builder
+	if m < 0 {
+		builder.WriteString("-")
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			builder.WriteString(" ")
+		}
+		builder.WriteRune(digit)
+	}
+	if exponent > 0 {
+		builder.WriteString(",")
+		builder.WriteString(fraction)
+	}
+	if currency != "" {
+		builder.WriteString(" ")
+		builder.WriteString(string(currency))
+	}
+	return builder.String()
+}
+
+//ParseMoney разбирает сумму, введённую пользователем, в минимальные единицы валюты.
+//Понимает "1 234,56", "1234.56", "-10" и код валюты в конце, если он совпадает с currency
+func ParseMoney(s string, currency Currency) (Money, error) {
+	exponent, ok := currency.Exponent()
+	if !ok {
+		return 0, ErrInvalidMoney
+	}
+
+	s = strings.TrimSpace(s)
+	if code := string(currency); len(s) > len(code) && strings.EqualFold(s[len(s)-len(code):], code) {
+		s = strings.TrimSpace(s[:len(s)-len(code)])
+	}
+	//пробелы, в том числе неразрывные, разделяют разряды
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	s = strings.Replace(s, ",", ".", 1)
+	parts := strings.Split(s, ".")
+	if len(parts) > 2 || parts[0] == "" || !onlyDigits(parts[0]) {
+		return 0, ErrInvalidMoney
+	}
+	fraction := ""
+	if len(parts) == 2 {
+		fraction = parts[1]
+		if fraction == "" || len(fraction) > exponent || !onlyDigits(fraction) {
+			return 0, ErrInvalidMoney
+		}
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	amount, err := strconv.ParseInt(sign+parts[0]+fraction, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, ErrMoneyOverflow
+	}
+	if err != nil {
+		return 0, ErrInvalidMoney
+	}
+	return Money(amount), nil
+}
+
+func onlyDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestMoney_Add(t *testing.T) {
+	sum, err := Money(100).Add(250)
+	if err != nil || sum != 350 {
+		t.Errorf("Add(): sum = %d, error = %v", sum, err)
+	}
+	_, err = MaxMoney.Add(1)
+	if err != ErrMoneyOverflow {
+		t.Errorf("Add(): must return ErrMoneyOverflow, returned = %v", err)
+	}
+	_, err = MinMoney.Add(-1)
+	if err != ErrMoneyOverflow {
+		t.Errorf("Add(): must return ErrMoneyOverflow, returned = %v", err)
+	}
+}
+
+func TestMoney_Sub(t *testing.T) {
+	diff, err := Money(100).Sub(250)
+	if err != nil || diff != -150 {
+		t.Errorf("Sub(): diff = %d, error = %v", diff, err)
+	}
+	_, err = MinMoney.Sub(1)
+	if err != ErrMoneyOverflow {
+		t.Errorf("Sub(): must return ErrMoneyOverflow, returned = %v", err)
+	}
+	_, err = Money(0).Sub(MinMoney)
+	if err != ErrMoneyOverflow {
+		t.Errorf("Sub(): must return ErrMoneyOverflow, returned = %v", err)
+	}
+}
+
+func TestMoney_Mul(t *testing.T) {
+	product, err := Money(-1_50).Mul(3)
+	if err != nil || product != -4_50 {
+		t.Errorf("Mul(): product = %d, error = %v", product, err)
+	}
+	_, err = (MaxMoney / 2).Mul(3)
+	if err != ErrMoneyOverflow {
+		t.Errorf("Mul(): must return ErrMoneyOverflow, returned = %v", err)
+	}
+}
+
+func TestMoney_MulDiv(t *testing.T) {
+	share, err := MaxMoney.MulDiv(3, 4)
+	if err != nil || share != MaxMoney/4*3+2 {
+		t.Errorf("MulDiv(): share = %d, error = %v", share, err)
+	}
+	share, err = Money(-10).MulDiv(1, 3)
+	if err != nil || share != -3 {
+		t.Errorf("MulDiv(): share = %d, error = %v", share, err)
+	}
+	_, err = MaxMoney.MulDiv(2, 1)
+	if err != ErrMoneyOverflow {
+		t.Errorf("MulDiv(): must return ErrMoneyOverflow, returned = %v", err)
+	}
+	_, err = Money(1).MulDiv(1, 0)
+	if err != ErrInvalidMoney {
+		t.Errorf("MulDiv(): must return ErrInvalidMoney, returned = %v", err)
+	}
+}
+
+func TestMoney_Percent(t *testing.T) {
+	tests := []struct {
+		amount      Money
+		basisPoints int64
+		want        Money
+	}{
+		{10_000_00, 150, 15_000},
+		//половина округляется до чётного
+		{50, 1_000, 5},
+		{250, 100, 2},
+		{350, 100, 4},
+		{-250, 100, -2},
+		{-350, 100, -4},
+		{251, 100, 3},
+		{249, 100, 2},
+		{MaxMoney, 10_000, MaxMoney},
+	}
+	for _, tt := range tests {
+		got, err := tt.amount.Percent(tt.basisPoints)
+		if err != nil || got != tt.want {
+			t.Errorf("Percent(%d, %d) = %d, %v; want %d", tt.amount, tt.basisPoints, got, err, tt.want)
+		}
+	}
+	_, err := MaxMoney.Percent(20_000)
+	if err != ErrMoneyOverflow {
+		t.Errorf("Percent(): must return ErrMoneyOverflow, returned = %v", err)
+	}
+}
+
+func TestMoney_Allocate(t *testing.T) {
+	parts, err := Money(100).Allocate(3)
+	if err != nil {
+		t.Fatalf("Allocate(): error = %v", err)
+	}
+	if len(parts) != 3 || parts[0] != 34 || parts[1] != 33 || parts[2] != 33 {
+		t.Errorf("Allocate(): parts = %v", parts)
+	}
+
+	parts, _ = Money(-5).Allocate(2)
+	if parts[0] != -3 || parts[1] != -2 {
+		t.Errorf("Allocate(): parts = %v", parts)
+	}
+
+	_, err = Money(100).Allocate(0)
+	if err != ErrInvalidMoney {
+		t.Errorf("Allocate(): must return ErrInvalidMoney, returned = %v", err)
+	}
+}
+
+func TestMoney_Format(t *testing.T) {
+	tests := []struct {
+		amount   Money
+		currency Currency
+		want     string
+	}{
+		{1_234_56, TJS, "1 234,56 TJS"},
+		{5, TJS, "0,05 TJS"},
+		{-1_000_000_00, USD, "-1 000 000,00 USD"},
+		{1234, JPY, "1 234 JPY"},
+		{1_500, KWD, "1,500 KWD"},
+		{MinMoney, TJS, "-92 233 720 368 547 758,08 TJS"},
+		{12_00, "", "12,00"},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.Format(tt.currency); got != tt.want {
+			t.Errorf("Format(%d, %s) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency Currency
+		want     Money
+	}{
+		{"1 234,56 TJS", TJS, 1_234_56},
+		{"1 234.5", TJS, 1_234_50},
+		{"-10", TJS, -10_00},
+		{"+0,05", TJS, 5},
+		{"1 234 jpy", JPY, 1234},
+		{"1,5", KWD, 1_500},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.input, tt.currency)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, %v; want %d", tt.input, got, err, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "abc", "1,234", "1.2.3", "12 USD", "1,", ",5", "--1", "10 JPY"} {
+		_, err := ParseMoney(input, TJS)
+		if err != ErrInvalidMoney {
+			t.Errorf("ParseMoney(%q): must return ErrInvalidMoney, returned = %v", input, err)
+		}
+	}
+	_, err := ParseMoney("1", "XXX")
+	if err != ErrInvalidMoney {
+		t.Errorf("ParseMoney(): must return ErrInvalidMoney for unknown currency, returned = %v", err)
+	}
+	_, err = ParseMoney("100 000 000 000 000 000", TJS)
+	if err != ErrMoneyOverflow {
+		t.Errorf("ParseMoney(): must return ErrMoneyOverflow, returned = %v", err)
+	}
+
+	amount := Money(98_765_43)
+	parsed, err := ParseMoney(amount.Format(EUR), EUR)
+	if err != nil || parsed != amount {
+		t.Errorf("ParseMoney(Format()) = %d, %v; want %d", parsed, err, amount)
+	}
+}
@@ -1,8 +1,42 @@
 package types
 
+import "time"
+
 //Money  представляет собой денежную сумму в минимальных единицах(центы, копейки, и т.д)
 type Money int64
 
+//Currency - код валюты по ISO 4217
+type Currency string
+
+//Поддерживаемые валюты
+const (
+	TJS Currency = "TJS"
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	RUB Currency = "RUB"
+	JPY Currency = "JPY"
+	KWD Currency = "KWD"
+)
+
+//DefaultCurrency - валюта счетов, созданных без указания валюты, и старых дампов
+const DefaultCurrency = TJS
+
+//currencyExponents - число знаков после запятой в минимальной единице валюты
+var currencyExponents = map[Currency]int{
+	TJS: 2,
+	USD: 2,
+	EUR: 2,
+	RUB: 2,
+	JPY: 0,
+	KWD: 3,
+}
+
+//Exponent возвращает число знаков минимальной единицы валюты; ok ложно для неизвестной валюты
+func (c Currency) Exponent() (exponent int, ok bool) {
+	exponent, ok = currencyExponents[c]
+	return exponent, ok
+}
+
 //Category  представляет собой категорию в которой был совершен платёж
 type PaymentCategory string
 
@@ -14,33 +48,359 @@ const (
 	PaymentStatusOk         PaymentStatus = "OK"
 	PaymentStatusFail       PaymentStatus = "FAIL"
 	PaymentStatusInProgress PaymentStatus = "INPROGRESS"
+	//PaymentStatusPendingConfirmation - крупный платёж ждёт подтверждения одноразовым кодом;
+	//деньги уже списаны и вернутся на счёт, если платёж не подтвердят
+	PaymentStatusPendingConfirmation PaymentStatus = "PENDING_CONFIRMATION"
 )
 
 //Payment представляет  информацию о платеже
 type Payment struct {
-	ID        string
-	AccountID int64
-	Amount    Money
-	Category  PaymentCategory
-	Status    PaymentStatus
+	ID        string          `json:"id"`
+	AccountID int64           `json:"accountId"`
+	Amount    Money           `json:"amount"`
+	Category  PaymentCategory `json:"category"`
+	Status    PaymentStatus   `json:"status"`
+	Currency  Currency        `json:"currency"`
+	Refunded  Money           `json:"refunded"`
+	//Fee - комиссия, списанная вместе с платежом
+	Fee         Money `json:"fee"`
+	FeeRefunded Money `json:"feeRefunded"`
+	//Details - значения полей шаблона, например номер абонента
+	Details   map[string]string `json:"details,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	//EnvelopeID - конверт, из которого оплачен платёж; пустой - основной баланс
+	EnvelopeID string `json:"envelopeId,omitempty"`
+	//Confirmation - запрос подтверждения, пока платёж ждёт одноразового кода
+	Confirmation *Challenge `json:"confirmation,omitempty"`
+}
+
+//Challenge - запрос подтверждения платежа одноразовым кодом
+type Challenge struct {
+	ExpiresAt    time.Time `json:"expiresAt"`
+	AttemptsLeft int       `json:"attemptsLeft"`
+}
+
+//ConfirmationPolicy определяет, какие платежи подтверждаются кодом: платежи больше Threshold.
+//Код действует TTL и допускает MaxAttempts попыток ввода; нулевой Threshold отключает подтверждение
+type ConfirmationPolicy struct {
+	Threshold   Money         `json:"threshold"`
+	TTL         time.Duration `json:"ttl"`
+	MaxAttempts int           `json:"maxAttempts"`
+}
+
+//Refund представляет информацию о частичном возврате платежа
+type Refund struct {
+	ID        string `json:"id"`
+	PaymentID string `json:"paymentId"`
+	AccountID int64  `json:"accountId"`
+	Amount    Money  `json:"amount"`
+	Fee       Money  `json:"fee"`
+}
+
+//FeeRule описывает комиссию за платежи в категории.
+//Percent задаётся в базисных пунктах: 150 означает 1,5%
+type FeeRule struct {
+	Category PaymentCategory `json:"category"`
+	Fixed    Money           `json:"fixed"`
+	Percent  int64           `json:"percent"`
+	//Min и Max ограничивают комиссию; нулевой Max означает отсутствие ограничения
+	Min Money `json:"min"`
+	Max Money `json:"max"`
+	//FreeUpTo - платежи на сумму не больше указанной проходят без комиссии
+	FreeUpTo Money `json:"freeUpTo"`
+}
+
+//CashbackRule описывает кэшбэк за платежи в категории; Percent в базисных пунктах
+type CashbackRule struct {
+	Category PaymentCategory `json:"category"`
+	Percent  int64           `json:"percent"`
+}
+
+//Reward представляет начисление кэшбэка; отрицательная сумма - списание
+//ранее начисленного кэшбэка при отмене или возврате платежа
+type Reward struct {
+	ID        string    `json:"id"`
+	AccountID int64     `json:"accountId"`
+	PaymentID string    `json:"paymentId"`
+	Amount    Money     `json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+//RewardSummary - итоги по кэшбэку счёта
+type RewardSummary struct {
+	Accrued    Money `json:"accrued"`
+	ClawedBack Money `json:"clawedBack"`
+	Paid       Money `json:"paid"`
+}
+
+//ScheduleKind определяет периодичность платежа по расписанию
+type ScheduleKind string
+
+//Предопределённые виды расписаний
+const (
+	ScheduleOnce    ScheduleKind = "ONCE"
+	ScheduleDaily   ScheduleKind = "DAILY"
+	ScheduleWeekly  ScheduleKind = "WEEKLY"
+	ScheduleMonthly ScheduleKind = "MONTHLY"
+	//ScheduleBusinessDay - N-й рабочий день (пн-пт) каждого месяца
+	ScheduleBusinessDay ScheduleKind = "BUSINESSDAY"
+)
+
+//ScheduleRule описывает, когда выполнять платёж по избранному.
+//Start - дата и время первого платежа; время суток сохраняется для всех повторов
+type ScheduleRule struct {
+	Kind        ScheduleKind `json:"kind"`
+	Start       time.Time    `json:"start"`
+	BusinessDay int          `json:"businessDay"`
+}
+
+//Schedule представляет платёж по избранному, выполняемый по расписанию
+type Schedule struct {
+	ID         string       `json:"id"`
+	FavoriteID string       `json:"favoriteId"`
+	Rule       ScheduleRule `json:"rule"`
+	//Due - плановое время текущего платежа, NextRun - время следующей попытки
+	Due      time.Time `json:"due"`
+	NextRun  time.Time `json:"nextRun"`
+	Attempts int       `json:"attempts"`
+	Active   bool      `json:"active"`
+}
+
+//ScheduledRun - результат одной попытки платежа по расписанию
+type ScheduledRun struct {
+	ScheduleID string    `json:"scheduleId"`
+	At         time.Time `json:"at"`
+	PaymentID  string    `json:"paymentId"`
+	Error      string    `json:"error"`
+}
+
+//RetryPolicy определяет повторы неудавшихся платежей по расписанию
+type RetryPolicy struct {
+	MaxAttempts int           `json:"maxAttempts"`
+	Delay       time.Duration `json:"delay"`
+}
+
+//PINPolicy определяет блокировку ПИН-кода: после MaxAttempts неверных попыток подряд
+//проверка блокируется на Lockout, и каждая следующая блокировка вдвое длиннее, но не дольше MaxLockout
+type PINPolicy struct {
+	MaxAttempts int           `json:"maxAttempts"`
+	Lockout     time.Duration `json:"lockout"`
+	MaxLockout  time.Duration `json:"maxLockout"`
+}
+
+//LimitKind определяет, какой лимит расходов был превышен
+type LimitKind string
+
+//Предопределённые виды лимитов
+const (
+	LimitPerTransaction LimitKind = "PER_TRANSACTION"
+	LimitDaily          LimitKind = "DAILY"
+	LimitMonthly        LimitKind = "MONTHLY"
+	LimitCategory       LimitKind = "CATEGORY"
+)
+
+//Limits - лимиты расходов счёта; нулевое значение означает отсутствие лимита.
+//Daily и Monthly считаются за календарный день и месяц
+type Limits struct {
+	PerTransaction Money `json:"perTransaction"`
+	Daily          Money `json:"daily"`
+	Monthly        Money `json:"monthly"`
+	//Categories - месячные лимиты по категориям
+	Categories map[PaymentCategory]Money `json:"categories,omitempty"`
+}
+
+//Category описывает категорию платежей в справочнике.
+//Names - названия для показа по коду языка, MCC - четырёхзначный код вида деятельности
+type Category struct {
+	Code   PaymentCategory   `json:"code"`
+	Names  map[string]string `json:"names,omitempty"`
+	Parent PaymentCategory   `json:"parent,omitempty"`
+	MCC    string            `json:"mcc,omitempty"`
+}
+
+//CategoryTotal - траты по категории за период; Total включает вложенные категории
+type CategoryTotal struct {
+	Category PaymentCategory `json:"category"`
+	Parent   PaymentCategory `json:"parent,omitempty"`
+	Own      Money           `json:"own"`
+	Total    Money           `json:"total"`
+}
+
+//Budget - месячный бюджет счёта на категорию платежей
+type Budget struct {
+	AccountID int64           `json:"accountId"`
+	Category  PaymentCategory `json:"category"`
+	Amount    Money           `json:"amount"`
+}
+
+//BudgetStatus - траты по бюджету за текущий месяц
+type BudgetStatus struct {
+	Category  PaymentCategory `json:"category"`
+	Budget    Money           `json:"budget"`
+	Spent     Money           `json:"spent"`
+	Remaining Money           `json:"remaining"`
+}
+
+//BudgetAlert сообщает, что платёж PaymentID довёл траты до Threshold процентов бюджета
+type BudgetAlert struct {
+	AccountID int64           `json:"accountId"`
+	Category  PaymentCategory `json:"category"`
+	PaymentID string          `json:"paymentId"`
+	Threshold int             `json:"threshold"`
+	Budget    Money           `json:"budget"`
+	Spent     Money           `json:"spent"`
+}
+
+//Conversion - запись об обмене валюты между счетами одного владельца.
+//Курсы хранятся в миллионных долях: сколько единиц To стоит одна единица From
+type Conversion struct {
+	ID            string    `json:"id"`
+	FromAccountID int64     `json:"fromAccountId"`
+	ToAccountID   int64     `json:"toAccountId"`
+	From          Currency  `json:"from"`
+	To            Currency  `json:"to"`
+	Amount        Money     `json:"amount"`
+	Converted     Money     `json:"converted"`
+	Rate          int64     `json:"rate"`
+	Spread        int64     `json:"spread"`
+	AppliedRate   int64     `json:"appliedRate"`
+	CreatedAt     time.Time `json:"createdAt"`
 }
 
 type Phone string
 
 //Account представляет информацию о счёте пользователя
 type Account struct {
-	ID      int64
-	Phone   Phone
-	Balance Money
+	ID       int64         `json:"id"`
+	Phone    Phone         `json:"phone"`
+	Balance  Money         `json:"balance"`
+	Currency Currency      `json:"currency"`
+	Status   AccountStatus `json:"status"`
+	Tier     KYCTier       `json:"tier"`
+}
+
+//KYCTier - уровень идентификации клиента; чем он выше, тем мягче ограничения счёта
+type KYCTier string
+
+//Уровни идентификации по возрастанию
+const (
+	KYCTierAnonymous KYCTier = "ANONYMOUS"
+	KYCTierBasic     KYCTier = "BASIC"
+	KYCTierFull      KYCTier = "FULL"
+)
+
+//Profile - анкета клиента. Для уровня BASIC нужны имя и дата рождения, для FULL - ещё и документ
+type Profile struct {
+	Name      string    `json:"name"`
+	BirthDate time.Time `json:"birthDate"`
+	Document  string    `json:"document"`
+}
+
+//TierLimits - ограничения уровня идентификации; ноль означает отсутствие ограничения.
+//Оборот за месяц - сумма пополнений и платежей с начала месяца
+type TierLimits struct {
+	MaxBalance      Money `json:"maxBalance"`
+	MonthlyTurnover Money `json:"monthlyTurnover"`
+}
+
+//UpgradeStatus - состояние заявки на повышение уровня
+type UpgradeStatus string
+
+//Статусы заявок на повышение уровня
+const (
+	UpgradeStatusPending  UpgradeStatus = "PENDING"
+	UpgradeStatusApproved UpgradeStatus = "APPROVED"
+	UpgradeStatusRejected UpgradeStatus = "REJECTED"
+)
+
+//UpgradeRequest - заявка на повышение уровня идентификации счёта
+type UpgradeRequest struct {
+	ID        string        `json:"id"`
+	AccountID int64         `json:"accountId"`
+	Tier      KYCTier       `json:"tier"`
+	Status    UpgradeStatus `json:"status"`
+	//Reason - причина отказа
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+//PhoneChange - запись истории смены номера телефона счёта
+type PhoneChange struct {
+	AccountID int64     `json:"accountId"`
+	Previous  Phone     `json:"previous"`
+	Phone     Phone     `json:"phone"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+//AccountStatus - состояние счёта
+type AccountStatus string
+
+//Статусы счёта: замороженный счёт временно заблокирован, закрытый - до повторного открытия
+const (
+	AccountStatusActive AccountStatus = "ACTIVE"
+	AccountStatusFrozen AccountStatus = "FROZEN"
+	AccountStatusClosed AccountStatus = "CLOSED"
+)
+
+//Envelope - конверт внутри счёта, в котором откладываются деньги.
+//Его баланс не входит в Account.Balance
+type Envelope struct {
+	ID        string `json:"id"`
+	AccountID int64  `json:"accountId"`
+	Name      string `json:"name"`
+	Balance   Money  `json:"balance"`
+}
+
+//Goal - цель накопления в конверте. Если RoundUp больше нуля, каждый платёж
+//с основного баланса округляется вверх до кратного RoundUp, а разница
+//переносится в конверт цели
+type Goal struct {
+	ID          string    `json:"id"`
+	EnvelopeID  string    `json:"envelopeId"`
+	AccountID   int64     `json:"accountId"`
+	Target      Money     `json:"target"`
+	Deadline    time.Time `json:"deadline"`
+	RoundUp     Money     `json:"roundUp"`
+	Completed   bool      `json:"completed"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+//GoalProgress - состояние накопления по цели
+type GoalProgress struct {
+	GoalID    string `json:"goalId"`
+	Target    Money  `json:"target"`
+	Saved     Money  `json:"saved"`
+	Remaining Money  `json:"remaining"`
+	//Percent - накопленная доля цели в процентах, не больше 100
+	Percent   int  `json:"percent"`
+	Completed bool `json:"completed"`
+	Overdue   bool `json:"overdue"`
 }
 
 //Favorite представляет информацию о избранное
 type Favorite struct {
-	ID        string
-	AccountID int64
-	Name      string
-	Amount    Money
-	Category  PaymentCategory
+	ID        string          `json:"id"`
+	AccountID int64           `json:"accountId"`
+	Name      string          `json:"name"`
+	Amount    Money           `json:"amount"`
+	Category  PaymentCategory `json:"category"`
+	//Position - порядок избранного в списке счёта, начиная с нуля
+	Position int `json:"position"`
+	//VariableAmount означает, что сумма указывается при каждом платеже по шаблону
+	VariableAmount bool            `json:"variableAmount"`
+	Fields         []TemplateField `json:"fields,omitempty"`
+}
+
+//TemplateField описывает поле, которое заполняется при платеже по шаблону
+type TemplateField struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+}
+
+//TemplateOverrides - значения, передаваемые при платеже по шаблону
+type TemplateOverrides struct {
+	Amount Money             `json:"amount"`
+	Fields map[string]string `json:"fields"`
 }
 
 type Progress struct {
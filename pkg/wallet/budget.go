@@ -0,0 +1,172 @@
+package wallet
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+//budgetThresholds - проценты бюджета, при достижении которых вызывается обработчик
+var budgetThresholds = []int{80, 100}
+
+//BudgetAlertHandler вызывается, когда платёж доводит траты до порога бюджета
+type BudgetAlertHandler func(alert types.BudgetAlert)
+
+//SetBudgetAlertHandler задаёт обработчик уведомлений о бюджете; nil отключает уведомления
+func (s *Service) SetBudgetAlertHandler(handler BudgetAlertHandler) {
+	s.budgetAlert = handler
+}
+
+//SetBudget задаёт месячный бюджет счёта на категорию, заменяя прежний
+func (s *Service) SetBudget(accountID int64, category types.PaymentCategory, amount types.Money) (*types.Budget, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	category, err = s.resolveCategory(category)
+	if err != nil {
+		return nil, err
+	}
+
+	budget := s.findBudget(accountID, category)
+	if budget == nil {
+		budget = &types.Budget{AccountID: accountID, Category: category}
+		s.budgets = append(s.budgets, budget)
+	}
+	budget.Amount = amount
+	return budget, nil
+}
+
+//RemoveBudget удаляет бюджет счёта на категорию, если он был задан
+func (s *Service) RemoveBudget(accountID int64, category types.PaymentCategory) {
+	for i, budget := range s.budgets {
+		if budget.AccountID == accountID && s.sameCategory(budget.Category, category) {
+			s.budgets = append(s.budgets[:i], s.budgets[i+1:]...)
+			return
+		}
+	}
+}
+
+//Budgets возвращает траты по бюджетам счёта за текущий месяц, упорядоченные по категории.
+//Комиссия в бюджет не входит, как и в CategoryReport
+func (s *Service) Budgets(accountID int64) ([]types.BudgetStatus, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	since := startOfMonth(s.now())
+	statuses := make([]types.BudgetStatus, 0)
+	for _, budget := range s.budgets {
+		if budget.AccountID != accountID {
+			continue
+		}
+		spent := s.paid(accountID, since, budget.Category, false)
+		remaining := budget.Amount - spent
+		if remaining < 0 {
+			remaining = 0
+		}
+		statuses = append(statuses, types.BudgetStatus{
+			Category:  budget.Category,
+			Budget:    budget.Amount,
+			Spent:     spent,
+			Remaining: remaining,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Category < statuses[j].Category
+	})
+	return statuses, nil
+}
+
+func (s *Service) findBudget(accountID int64, category types.PaymentCategory) *types.Budget {
+	for _, budget := range s.budgets {
+		if budget.AccountID == accountID && s.sameCategory(budget.Category, category) {
+			return budget
+		}
+	}
+	return nil
+}
+
+//notifyBudget вызывает обработчик для каждого порога, который пересёк новый платёж,
+//в бюджете его категории и бюджетах родительских категорий
+func (s *Service) notifyBudget(payment *types.Payment) {
+	if s.budgetAlert == nil {
+		return
+	}
+	for _, category := range s.categoryPath(payment.Category) {
+		budget := s.findBudget(payment.AccountID, category)
+		if budget == nil {
+			continue
+		}
+
+		spent := s.paid(payment.AccountID, startOfMonth(payment.CreatedAt), category, false)
+		before := spent - payment.Amount
+		for _, threshold := range budgetThresholds {
+			limit := int64(budget.Amount) * int64(threshold)
+			if int64(before)*100 < limit && int64(spent)*100 >= limit {
+				s.budgetAlert(types.BudgetAlert{
+					AccountID: payment.AccountID,
+					Category:  category,
+					PaymentID: payment.ID,
+					Threshold: threshold,
+					Budget:    budget.Amount,
+					Spent:     spent,
+				})
+			}
+		}
+	}
+}
+
+func (s *Service) exportBudgets(dir string) error {
+	records := make([][]string, 0, len(s.budgets))
+	for _, budget := range s.budgets {
+		records = append(records, []string{
+			strconv.FormatInt(budget.AccountID, 10),
+			escape(string(budget.Category)),
+			strconv.FormatInt(int64(budget.Amount), 10),
+		})
+	}
+	return writeDump(dir+"/budgets.dump", records)
+}
+
+func (s *Service) importBudgets(dir string) error {
+	records, err := readDump(dir + "/budgets.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 3 {
+			err = fmt.Errorf("budgets.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		accountID, err := strconv.ParseInt(splits[0], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		category, err := unescape(splits[1])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		amount, err := strconv.ParseInt(splits[2], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		s.budgets = append(s.budgets, &types.Budget{
+			AccountID: accountID,
+			Category:  types.PaymentCategory(category),
+			Amount:    types.Money(amount),
+		})
+	}
+	return nil
+}
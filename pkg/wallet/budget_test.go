@@ -0,0 +1,145 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestService_Budgets_alerts(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2024, time.May, 10, 12, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	alerts := make([]types.BudgetAlert, 0)
+	s.SetBudgetAlertHandler(func(alert types.BudgetAlert) {
+		alerts = append(alerts, alert)
+	})
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 10_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.SetBudget(account.ID, "cafe", 500_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Pay(account.ID, 300_00, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Pay(account.ID, 1_000_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("Pay(): alerts below 80%% = %v", alerts)
+	}
+	payment, err := s.Pay(account.ID, 250_00, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 || alerts[0].Threshold != 80 || alerts[1].Threshold != 100 || alerts[1].PaymentID != payment.ID || alerts[1].Spent != 550_00 {
+		t.Errorf("Pay(): alerts = %v", alerts)
+	}
+	_, err = s.Pay(account.ID, 10_00, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 {
+		t.Errorf("Pay(): thresholds must fire once, alerts = %v", alerts)
+	}
+
+	statuses, err := s.Budgets(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Spent != 560_00 || statuses[0].Remaining != 0 {
+		t.Errorf("Budgets(): statuses = %v", statuses)
+	}
+
+	now = now.AddDate(0, 1, 0)
+	statuses, _ = s.Budgets(account.ID)
+	if statuses[0].Spent != 0 || statuses[0].Remaining != 500_00 {
+		t.Errorf("Budgets(): new month must start from zero, statuses = %v", statuses)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported.RemoveBudget(account.ID, "auto")
+	statuses, _ = imported.Budgets(account.ID)
+	if len(statuses) != 1 || statuses[0].Budget != 500_00 {
+		t.Errorf("Import(): statuses = %v", statuses)
+	}
+	imported.RemoveBudget(account.ID, "cafe")
+	statuses, _ = imported.Budgets(account.ID)
+	if len(statuses) != 0 {
+		t.Errorf("RemoveBudget(): statuses = %v", statuses)
+	}
+}
+
+func TestService_Budgets_fee(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2024, time.May, 10, 12, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	alerts := make([]types.BudgetAlert, 0)
+	s.SetBudgetAlertHandler(func(alert types.BudgetAlert) {
+		alerts = append(alerts, alert)
+	})
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 10_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetFeeRule(types.FeeRule{Category: "cafe", Fixed: 50_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.SetBudget(account.ID, "cafe", 500_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//комиссия в бюджет не входит: порог пересекает только сумма платежей
+	_, err = s.Pay(account.ID, 380_00, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("Pay(): fee must not count toward the budget, alerts = %v", alerts)
+	}
+	_, err = s.Pay(account.ID, 30_00, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Threshold != 80 || alerts[0].Spent != 410_00 {
+		t.Errorf("Pay(): alerts = %v", alerts)
+	}
+
+	statuses, err := s.Budgets(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := s.CategoryReport(account.ID, startOfMonth(now), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || len(report) != 1 || statuses[0].Spent != report[0].Total {
+		t.Errorf("Budgets() = %v must match CategoryReport() = %v", statuses, report)
+	}
+}
@@ -0,0 +1,257 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+var ErrCategoryNotFound = errors.New("category not found")
+var ErrCategoryExists = errors.New("category already registered")
+var ErrInvalidCategory = errors.New("invalid category")
+var ErrUnknownCategory = errors.New("unknown payment category")
+
+//RegisterCategory добавляет категорию в справочник. Родитель должен быть зарегистрирован раньше.
+//Пока справочник пуст, Pay принимает любые категории, как и раньше, если не включён SetStrictCategories
+func (s *Service) RegisterCategory(category types.Category) (*types.Category, error) {
+	category.Code = types.PaymentCategory(strings.TrimSpace(string(category.Code)))
+	if category.Code == "" || !validMCC(category.MCC) {
+		return nil, ErrInvalidCategory
+	}
+	if s.findCategory(category.Code) != nil {
+		return nil, ErrCategoryExists
+	}
+	if category.Parent != "" {
+		parent := s.findCategory(category.Parent)
+		if parent == nil {
+			return nil, ErrInvalidCategory
+		}
+		category.Parent = parent.Code
+	}
+
+	names := make(map[string]string)
+	for lang, name := range category.Names {
+		names[lang] = name
+	}
+	category.Names = names
+	s.categories = append(s.categories, &category)
+	return &category, nil
+}
+
+//SetStrictCategories запрещает категории вне справочника и тогда, когда справочник пуст,
+//чтобы опечатка в категории не заводила новую категорию
+func (s *Service) SetStrictCategories(strict bool) {
+	s.strictCategories = strict
+}
+
+//FindCategory ищет категорию по коду без учёта регистра
+func (s *Service) FindCategory(code types.PaymentCategory) (*types.Category, error) {
+	category := s.findCategory(code)
+	if category == nil {
+		return nil, ErrCategoryNotFound
+	}
+	return category, nil
+}
+
+//Categories возвращает справочник категорий в порядке регистрации
+func (s *Service) Categories() []*types.Category {
+	categories := make([]*types.Category, len(s.categories))
+	copy(categories, s.categories)
+	return categories
+}
+
+//CategoryReport возвращает траты счёта по категориям за период [from, to).
+//Траты вложенных категорий суммируются в Total каждого из родителей
+func (s *Service) CategoryReport(accountID int64, from time.Time, to time.Time) ([]types.CategoryTotal, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[types.PaymentCategory]*types.CategoryTotal)
+	total := func(code types.PaymentCategory) *types.CategoryTotal {
+		if totals[code] == nil {
+			totals[code] = &types.CategoryTotal{Category: code}
+			if category := s.findCategory(code); category != nil {
+				totals[code].Parent = category.Parent
+			}
+		}
+		return totals[code]
+	}
+	for _, payment := range s.payments {
+		if payment.AccountID != accountID || payment.Status == types.PaymentStatusFail {
+			continue
+		}
+		if payment.CreatedAt.Before(from) || !payment.CreatedAt.Before(to) {
+			continue
+		}
+		amount := payment.Amount - payment.Refunded
+		total(s.canonicalCategory(payment.Category)).Own += amount
+		for _, code := range s.categoryPath(payment.Category) {
+			total(code).Total += amount
+		}
+	}
+
+	report := make([]types.CategoryTotal, 0, len(totals))
+	for _, total := range totals {
+		report = append(report, *total)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Category < report[j].Category
+	})
+	return report, nil
+}
+
+func (s *Service) findCategory(code types.PaymentCategory) *types.Category {
+	for _, category := range s.categories {
+		if strings.EqualFold(string(category.Code), string(code)) {
+			return category
+		}
+	}
+	return nil
+}
+
+//resolveCategory приводит категорию платежа к коду из справочника
+func (s *Service) resolveCategory(code types.PaymentCategory) (types.PaymentCategory, error) {
+	if len(s.categories) == 0 && !s.strictCategories {
+		return code, nil
+	}
+	category := s.findCategory(code)
+	if category == nil {
+		return "", ErrUnknownCategory
+	}
+	return category.Code, nil
+}
+
+//canonicalCategory возвращает код категории из справочника, а незнакомую категорию - как есть
+func (s *Service) canonicalCategory(code types.PaymentCategory) types.PaymentCategory {
+	category := s.findCategory(code)
+	if category == nil {
+		return code
+	}
+	return category.Code
+}
+
+//sameCategory сравнивает категории по коду из справочника, как findCategory: категория,
+//сохранённая до регистрации в справочнике, может отличаться от него регистром
+func (s *Service) sameCategory(a types.PaymentCategory, b types.PaymentCategory) bool {
+	return s.canonicalCategory(a) == s.canonicalCategory(b)
+}
+
+//categoryPath возвращает категорию и всех её родителей, начиная с самой категории;
+//коды в пути приведены к справочнику
+func (s *Service) categoryPath(code types.PaymentCategory) []types.PaymentCategory {
+	path := []types.PaymentCategory{s.canonicalCategory(code)}
+	category := s.findCategory(code)
+	for category != nil && category.Parent != "" {
+		path = append(path, category.Parent)
+		category = s.findCategory(category.Parent)
+	}
+	return path
+}
+
+//inCategory проверяет, что code совпадает с категорией ancestor или вложен в неё
+func (s *Service) inCategory(code types.PaymentCategory, ancestor types.PaymentCategory) bool {
+	ancestor = s.canonicalCategory(ancestor)
+	for _, parent := range s.categoryPath(code) {
+		if parent == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+func validMCC(mcc string) bool {
+	if mcc == "" {
+		return true
+	}
+	if len(mcc) != 4 {
+		return false
+	}
+	for _, r := range mcc {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Service) exportCategories(dir string) error {
+	records := make([][]string, 0, len(s.categories))
+	for _, category := range s.categories {
+		langs := make([]string, 0, len(category.Names))
+		for lang := range category.Names {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		names := make([]string, len(langs))
+		for i, lang := range langs {
+			names[i] = escape(lang) + "=" + escape(category.Names[lang])
+		}
+		records = append(records, []string{
+			escape(string(category.Code)),
+			strings.Join(names, "&"),
+			escape(string(category.Parent)),
+			category.MCC,
+		})
+	}
+	return writeDump(dir+"/categories.dump", records)
+}
+
+func (s *Service) importCategories(dir string) error {
+	records, err := readDump(dir + "/categories.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 4 {
+			err = fmt.Errorf("categories.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		code, err := unescape(splits[0])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		names := make(map[string]string)
+		if splits[1] != "" {
+			for _, part := range strings.Split(splits[1], "&") {
+				pair := strings.Split(part, "=")
+				if len(pair) != 2 {
+					err = fmt.Errorf("categories.dump: wrong record %v", splits)
+					log.Print(err)
+					return err
+				}
+				lang, err := unescape(pair[0])
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+				name, err := unescape(pair[1])
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+				names[lang] = name
+			}
+		}
+		parent, err := unescape(splits[2])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		s.categories = append(s.categories, &types.Category{
+			Code:   types.PaymentCategory(code),
+			Names:  names,
+			Parent: types.PaymentCategory(parent),
+			MCC:    splits[3],
+		})
+	}
+	return nil
+}
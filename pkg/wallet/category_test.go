@@ -0,0 +1,271 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func addCategories(t *testing.T, s *testService) {
+	t.Helper()
+	categories := []types.Category{
+		{Code: "food", Names: map[string]string{"ru": "Еда", "en": "Food"}},
+		{Code: "cafe", Names: map[string]string{"ru": "Кафе"}, Parent: "food", MCC: "5812"},
+		{Code: "auto", MCC: "5541"},
+	}
+	for _, category := range categories {
+		_, err := s.RegisterCategory(category)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestService_RegisterCategory(t *testing.T) {
+	s := newTestService()
+	addCategories(t, s)
+
+	_, err := s.RegisterCategory(types.Category{Code: "Cafe"})
+	if err != ErrCategoryExists {
+		t.Errorf("RegisterCategory(): must return ErrCategoryExists, returned = %v", err)
+	}
+	_, err = s.RegisterCategory(types.Category{Code: "taxi", Parent: "transport"})
+	if err != ErrInvalidCategory {
+		t.Errorf("RegisterCategory(): unknown parent must return ErrInvalidCategory, returned = %v", err)
+	}
+	_, err = s.RegisterCategory(types.Category{Code: "taxi", MCC: "41x"})
+	if err != ErrInvalidCategory {
+		t.Errorf("RegisterCategory(): wrong MCC must return ErrInvalidCategory, returned = %v", err)
+	}
+
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Pay(account.ID, 10_00, "Cafee")
+	if err != ErrUnknownCategory {
+		t.Errorf("Pay(): must return ErrUnknownCategory, returned = %v", err)
+	}
+	payment, err := s.Pay(account.ID, 10_00, "CAFE")
+	if err != nil || payment.Category != "cafe" {
+		t.Errorf("Pay(): category must be taken from registry, payment = %v, error = %v", payment, err)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	category, err := imported.FindCategory("cafe")
+	if err != nil || category.Parent != "food" || category.MCC != "5812" || category.Names["ru"] != "Кафе" {
+		t.Errorf("Import(): category = %v, error = %v", category, err)
+	}
+	if len(imported.Categories()) != 3 {
+		t.Errorf("Import(): categories = %v", imported.Categories())
+	}
+}
+
+func TestService_CategoryReport(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	addCategories(t, s)
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerts := make([]types.BudgetAlert, 0)
+	s.SetBudgetAlertHandler(func(alert types.BudgetAlert) {
+		alerts = append(alerts, alert)
+	})
+	_, err = s.SetBudget(account.ID, "food", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, payment := range []struct {
+		amount   types.Money
+		category types.PaymentCategory
+	}{{30_00, "food"}, {50_00, "cafe"}, {20_00, "auto"}} {
+		_, err = s.Pay(account.ID, payment.amount, payment.category)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := s.CategoryReport(account.ID, startOfMonth(now), now.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []types.CategoryTotal{
+		{Category: "auto", Own: 20_00, Total: 20_00},
+		{Category: "cafe", Parent: "food", Own: 50_00, Total: 50_00},
+		{Category: "food", Own: 30_00, Total: 80_00},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("CategoryReport(): report = %v", report)
+	}
+	for i := range want {
+		if report[i] != want[i] {
+			t.Errorf("CategoryReport(): report[%d] = %v, want %v", i, report[i], want[i])
+		}
+	}
+	if len(alerts) != 1 || alerts[0].Category != "food" || alerts[0].Spent != 80_00 {
+		t.Errorf("Pay(): cafe payments must count towards food budget, alerts = %v", alerts)
+	}
+}
+
+func TestService_CategoryReport_case(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//пока справочник пуст, категории сохраняются как переданы
+	_, err = s.SetBudget(account.ID, "Food", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetLimits(account.ID, types.Limits{Categories: map[types.PaymentCategory]types.Money{"FOOD": 50_00}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Pay(account.ID, 30_00, "Food")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addCategories(t, s)
+
+	_, err = s.Pay(account.ID, 10_00, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Pay(account.ID, 20_00, "food")
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Pay(): limit must count payments of any case, returned = %v", err)
+	}
+	budgets, err := s.Budgets(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(budgets) != 1 || budgets[0].Spent != 40_00 {
+		t.Errorf("Budgets(): budgets = %v", budgets)
+	}
+	_, err = s.SetBudget(account.ID, "food", 200_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	budgets, err = s.Budgets(account.ID)
+	if err != nil || len(budgets) != 1 || budgets[0].Budget != 200_00 {
+		t.Errorf("SetBudget(): must replace the budget of any case, budgets = %v, error = %v", budgets, err)
+	}
+
+	report, err := s.CategoryReport(account.ID, startOfMonth(now), now.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []types.CategoryTotal{
+		{Category: "cafe", Parent: "food", Own: 10_00, Total: 10_00},
+		{Category: "food", Own: 30_00, Total: 40_00},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("CategoryReport(): report = %v", report)
+	}
+	for i := range want {
+		if report[i] != want[i] {
+			t.Errorf("CategoryReport(): report[%d] = %v, want %v", i, report[i], want[i])
+		}
+	}
+}
+
+func TestService_categoryRules(t *testing.T) {
+	s := newTestService()
+	addCategories(t, s)
+	err := s.SetFeeRule(types.FeeRule{Category: "FOOD", Fixed: 1_00})
+	if err != nil {
+		t.Fatalf("SetFeeRule(): error = %v", err)
+	}
+	err = s.SetCashbackRule(types.CashbackRule{Category: "Food", Percent: 100})
+	if err != nil {
+		t.Fatalf("SetCashbackRule(): error = %v", err)
+	}
+	err = s.SetFeeRule(types.FeeRule{Category: "taxi", Fixed: 1_00})
+	if err != ErrUnknownCategory {
+		t.Errorf("SetFeeRule(): must return ErrUnknownCategory, returned = %v", err)
+	}
+	err = s.SetCashbackRule(types.CashbackRule{Category: "taxi", Percent: 100})
+	if err != ErrUnknownCategory {
+		t.Errorf("SetCashbackRule(): must return ErrUnknownCategory, returned = %v", err)
+	}
+
+	//правила родителя действуют на вложенную категорию
+	account, _, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := s.Pay(account.ID, 100_00, "Cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Fee != 1_00 {
+		t.Errorf("Pay(): parent fee rule must apply, fee = %v", payment.Fee)
+	}
+	balance := account.Balance
+	err = s.CompletePayment(payment.ID)
+	if err != nil || account.Balance != balance+1_00 {
+		t.Errorf("CompletePayment(): parent cashback rule must apply, balance = %v, error = %v", account.Balance, err)
+	}
+	if fee := s.Fee(100_00, "auto"); fee != 0 {
+		t.Errorf("Fee(): unrelated category fee = %v", fee)
+	}
+	s.RemoveFeeRule("food")
+	if fee := s.Fee(100_00, "cafe"); fee != 0 {
+		t.Errorf("Fee() after RemoveFeeRule = %v", fee)
+	}
+}
+
+func TestService_SetStrictCategories(t *testing.T) {
+	s := newTestService()
+	s.SetStrictCategories(true)
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Pay(account.ID, 100_00, "auot")
+	if err != ErrUnknownCategory {
+		t.Errorf("Pay(): empty strict registry must return ErrUnknownCategory, returned = %v", err)
+	}
+	_, err = s.RegisterCategory(types.Category{Code: "auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Pay(account.ID, 100_00, "AUTO")
+	if err != nil {
+		t.Errorf("Pay(): error = %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+var ErrNoCodeNotifier = errors.New("confirmation code notifier is not set")
+var ErrPaymentNotPending = errors.New("payment is not waiting for confirmation")
+var ErrPaymentNotConfirmed = errors.New("payment is not confirmed yet")
+var ErrWrongCode = errors.New("wrong confirmation code")
+var ErrCodeExpired = errors.New("confirmation code expired")
+var ErrCodeAttemptsExceeded = errors.New("confirmation attempts exceeded")
+
+//CodeNotifier доставляет клиенту одноразовый код подтверждения платежа, например по SMS.
+//Ошибка доставки отменяет платёж
+type CodeNotifier func(account *types.Account, payment *types.Payment, code string) error
+
+//Значения политики подтверждения по умолчанию
+const (
+	defaultConfirmationTTL      = 5 * time.Minute
+	defaultConfirmationAttempts = 3
+)
+
+//SetConfirmationPolicy задаёт порог, выше которого платежи подтверждаются кодом.
+//Нулевые TTL и MaxAttempts заменяются значениями по умолчанию: 5 минут и 3 попытки
+func (s *Service) SetConfirmationPolicy(policy types.ConfirmationPolicy) {
+	if policy.TTL <= 0 {
+		policy.TTL = defaultConfirmationTTL
+	}
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaultConfirmationAttempts
+	}
+	s.confirmationPolicy = policy
+}
+
+//SetConfirmationKey задаёт секрет, с которым коды подтверждения хешируются через HMAC:
+//без него код из дампа подбирается перебором. Если ключ не задан, используется случайный
+//ключ процесса, и после перезапуска ожидающие платежи подтвердить уже нельзя
+func (s *Service) SetConfirmationKey(key []byte) {
+	s.confirmationKey = append([]byte(nil), key...)
+}
+
+//SetCodeNotifier задаёт способ доставки кодов подтверждения
+func (s *Service) SetCodeNotifier(notifier CodeNotifier) {
+	s.codeNotifier = notifier
+}
+
+//ConfirmPayment подтверждает платёж одноразовым кодом. Просроченный код и исчерпанные
+//попытки отменяют платёж и возвращают деньги на счёт
+func (s *Service) ConfirmPayment(paymentID string, code string) (*types.Payment, error) {
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != types.PaymentStatusPendingConfirmation {
+		return nil, ErrPaymentNotPending
+	}
+
+	challenge := payment.Confirmation
+	if challenge == nil || !s.now().Before(challenge.ExpiresAt) {
+		err = s.cancelConfirmation(payment)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrCodeExpired
+	}
+	hash, err := s.hashCode(payment.ID, code)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(hash, s.confirmationCodes[payment.ID]) {
+		challenge.AttemptsLeft--
+		if challenge.AttemptsLeft <= 0 {
+			err = s.cancelConfirmation(payment)
+			if err != nil {
+				return nil, err
+			}
+			return nil, ErrCodeAttemptsExceeded
+		}
+		return nil, ErrWrongCode
+	}
+
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	payment.Status = types.PaymentStatusInProgress
+	s.dropChallenge(payment)
+	s.settlePayment(account, payment)
+	return payment, nil
+}
+
+//ExpireConfirmations отменяет платежи, код подтверждения которых просрочен,
+//и возвращает число отменённых платежей. Вызывается периодически вместе
+//с RunScheduledPayments
+func (s *Service) ExpireConfirmations() (int, error) {
+	now := s.now()
+	expired := 0
+	for _, payment := range s.payments {
+		if payment.Status != types.PaymentStatusPendingConfirmation {
+			continue
+		}
+		if payment.Confirmation != nil && now.Before(payment.Confirmation.ExpiresAt) {
+			continue
+		}
+		err := s.cancelConfirmation(payment)
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+func (s *Service) requiresConfirmation(amount types.Money) bool {
+	return s.confirmationPolicy.Threshold > 0 && amount > s.confirmationPolicy.Threshold
+}
+
+//issueChallenge переводит платёж в ожидание подтверждения и отправляет клиенту код
+func (s *Service) issueChallenge(account *types.Account, payment *types.Payment) error {
+	code, err := generateCode()
+	if err != nil {
+		return err
+	}
+	hash, err := s.hashCode(payment.ID, code)
+	if err != nil {
+		return err
+	}
+	payment.Status = types.PaymentStatusPendingConfirmation
+	payment.Confirmation = &types.Challenge{
+		ExpiresAt:    s.now().Add(s.confirmationPolicy.TTL),
+		AttemptsLeft: s.confirmationPolicy.MaxAttempts,
+	}
+	if s.confirmationCodes == nil {
+		s.confirmationCodes = make(map[string][]byte)
+	}
+	s.confirmationCodes[payment.ID] = hash
+	return s.codeNotifier(account, payment, code)
+}
+
+//cancelConfirmation отменяет неподтверждённый платёж так же, как Reject, но и
+//на закрытом счёте: удержанные деньги возвращаются в любом случае
+func (s *Service) cancelConfirmation(payment *types.Payment) error {
+	s.dropChallenge(payment)
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return err
+	}
+	return s.reject(payment, account)
+}
+
+func (s *Service) dropChallenge(payment *types.Payment) {
+	payment.Confirmation = nil
+	delete(s.confirmationCodes, payment.ID)
+}
+
+//generateCode возвращает случайный шестизначный код
+func generateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+//hashCode хеширует код вместе с платежом через HMAC с секретом сервиса, чтобы по
+//дампу нельзя было перебрать миллион возможных кодов
+func (s *Service) hashCode(paymentID string, code string) ([]byte, error) {
+	if s.confirmationKey == nil {
+		key := make([]byte, sha256.Size)
+		_, err := rand.Read(key)
+		if err != nil {
+			return nil, err
+		}
+		s.confirmationKey = key
+	}
+	mac := hmac.New(sha256.New, s.confirmationKey)
+	mac.Write([]byte(paymentID + ":" + code))
+	return mac.Sum(nil), nil
+}
+
+func (s *Service) exportConfirmations(dir string) error {
+	records := make([][]string, 0, len(s.confirmationCodes))
+	for _, payment := range s.payments {
+		if payment.Confirmation == nil {
+			continue
+		}
+		records = append(records, []string{
+			payment.ID,
+			hex.EncodeToString(s.confirmationCodes[payment.ID]),
+			formatTime(payment.Confirmation.ExpiresAt),
+			strconv.Itoa(payment.Confirmation.AttemptsLeft),
+		})
+	}
+	return writeDump(dir+"/confirmations.dump", records)
+}
+
+func (s *Service) importConfirmations(dir string) error {
+	records, err := readDump(dir + "/confirmations.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 4 {
+			err = fmt.Errorf("confirmations.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		payment, err := s.FindPaymentByID(splits[0])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		hash, err := hex.DecodeString(splits[1])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		expiresAt, err := parseTime(splits[2])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		attemptsLeft, err := strconv.Atoi(splits[3])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		payment.Confirmation = &types.Challenge{ExpiresAt: expiresAt, AttemptsLeft: attemptsLeft}
+		if s.confirmationCodes == nil {
+			s.confirmationCodes = make(map[string][]byte)
+		}
+		s.confirmationCodes[payment.ID] = hash
+	}
+	return nil
+}
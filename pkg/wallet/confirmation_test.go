@@ -0,0 +1,317 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+var testConfirmationKey = []byte("test confirmation key")
+
+//newConfirmationService возвращает сервис, который запоминает последний отправленный код
+func newConfirmationService(now *time.Time) (*testService, *string) {
+	s := newTestService()
+	s.SetClock(func() time.Time { return *now })
+	s.SetConfirmationPolicy(types.ConfirmationPolicy{Threshold: 1_000_00, TTL: time.Minute, MaxAttempts: 2})
+	s.SetConfirmationKey(testConfirmationKey)
+	code := new(string)
+	s.SetCodeNotifier(func(account *types.Account, payment *types.Payment, sent string) error {
+		*code = sent
+		return nil
+	})
+	return s, code
+}
+
+func TestService_Pay_confirmation(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s, code := newConfirmationService(&now)
+	account, _, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *code != "" {
+		t.Fatalf("Pay(): payment up to the threshold must not require confirmation")
+	}
+
+	payment, err := s.Pay(account.ID, 2_000_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != types.PaymentStatusPendingConfirmation || payment.Confirmation == nil {
+		t.Fatalf("Pay(): payment = %v, must wait for confirmation", payment)
+	}
+	if len(*code) != 6 || payment.Confirmation.AttemptsLeft != 2 || !payment.Confirmation.ExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("Pay(): code = %q, challenge = %+v", *code, payment.Confirmation)
+	}
+	if account.Balance != 7_000_00 {
+		t.Errorf("Pay(): pending payment must hold the amount, balance = %v", account.Balance)
+	}
+	err = s.CompletePayment(payment.ID)
+	if err != ErrPaymentNotConfirmed {
+		t.Errorf("CompletePayment(): must return ErrPaymentNotConfirmed, returned = %v", err)
+	}
+	_, err = s.Refund(payment.ID, 100)
+	if err != ErrPaymentNotConfirmed {
+		t.Errorf("Refund(): must return ErrPaymentNotConfirmed, returned = %v", err)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey := newTestService()
+	otherKey.SetClock(func() time.Time { return now })
+	otherKey.SetConfirmationKey([]byte("other key"))
+	err = otherKey.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = otherKey.ConfirmPayment(payment.ID, *code)
+	if err != ErrWrongCode {
+		t.Errorf("ConfirmPayment(): code hash must depend on the key, returned = %v", err)
+	}
+
+	imported := newTestService()
+	imported.SetClock(func() time.Time { return now })
+	imported.SetConfirmationKey(testConfirmationKey)
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = imported.ConfirmPayment(payment.ID, wrongCode(*code))
+	if err != ErrWrongCode {
+		t.Errorf("ConfirmPayment(): must return ErrWrongCode, returned = %v", err)
+	}
+	confirmed, err := imported.ConfirmPayment(payment.ID, *code)
+	if err != nil {
+		t.Fatalf("ConfirmPayment(): error = %v", err)
+	}
+	if confirmed.Status != types.PaymentStatusInProgress || confirmed.Confirmation != nil {
+		t.Errorf("ConfirmPayment(): payment = %v", confirmed)
+	}
+	_, err = imported.ConfirmPayment(payment.ID, *code)
+	if err != ErrPaymentNotPending {
+		t.Errorf("ConfirmPayment(): code must be one-time, returned = %v", err)
+	}
+}
+
+func TestService_ConfirmPayment_cancel(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s, code := newConfirmationService(&now)
+	account, _, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := s.Pay(account.ID, 2_000_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ConfirmPayment(payment.ID, wrongCode(*code))
+	if err != ErrWrongCode {
+		t.Errorf("ConfirmPayment(): must return ErrWrongCode, returned = %v", err)
+	}
+	_, err = s.ConfirmPayment(payment.ID, wrongCode(*code))
+	if err != ErrCodeAttemptsExceeded {
+		t.Errorf("ConfirmPayment(): must return ErrCodeAttemptsExceeded, returned = %v", err)
+	}
+	if payment.Status != types.PaymentStatusFail || account.Balance != 9_000_00 {
+		t.Errorf("ConfirmPayment(): payment must be cancelled, status = %v, balance = %v", payment.Status, account.Balance)
+	}
+
+	payment, err = s.Pay(account.ID, 2_000_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	_, err = s.ConfirmPayment(payment.ID, *code)
+	if err != ErrCodeExpired {
+		t.Errorf("ConfirmPayment(): must return ErrCodeExpired, returned = %v", err)
+	}
+	if payment.Status != types.PaymentStatusFail || account.Balance != 9_000_00 {
+		t.Errorf("ConfirmPayment(): payment must be cancelled, status = %v, balance = %v", payment.Status, account.Balance)
+	}
+
+	first, err := s.Pay(account.ID, 2_000_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(30 * time.Second)
+	second, err := s.Pay(account.ID, 2_000_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(30 * time.Second)
+	expired, err := s.ExpireConfirmations()
+	if err != nil || expired != 1 || first.Status != types.PaymentStatusFail || second.Status != types.PaymentStatusPendingConfirmation {
+		t.Errorf("ExpireConfirmations() = %d, %v, statuses = %v, %v", expired, err, first.Status, second.Status)
+	}
+}
+
+func TestService_ConfirmPayment_settle(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s, code := newConfirmationService(&now)
+	alerts := 0
+	s.SetBudgetAlertHandler(func(alert types.BudgetAlert) {
+		alerts++
+	})
+	account, _, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.SetBudget(account.ID, "auto", 2_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := s.CreateEnvelope(account.ID, "car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goal, err := s.CreateGoal(envelope.ID, 1_000_00, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetGoalRoundUp(goal.ID, 1_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, err := s.Pay(account.ID, 2_000_50, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alerts != 0 || envelope.Balance != 0 {
+		t.Errorf("Pay(): pending payment must not settle, alerts = %d, envelope = %v", alerts, envelope.Balance)
+	}
+	now = now.Add(time.Minute)
+	_, err = s.ExpireConfirmations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != types.PaymentStatusFail || alerts != 0 || envelope.Balance != 0 {
+		t.Errorf("ExpireConfirmations(): cancelled payment must not settle, alerts = %d, envelope = %v", alerts, envelope.Balance)
+	}
+
+	payment, err := s.Pay(account.ID, 2_000_50, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ConfirmPayment(payment.ID, *code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alerts == 0 || envelope.Balance != 50 {
+		t.Errorf("ConfirmPayment(): payment must settle, alerts = %d, envelope = %v", alerts, envelope.Balance)
+	}
+}
+
+func TestService_Pay_confirmationNotifier(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s, _ := newConfirmationService(&now)
+	account, _, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errDelivery := errors.New("sms gateway is down")
+	s.SetCodeNotifier(func(account *types.Account, payment *types.Payment, code string) error {
+		return errDelivery
+	})
+	_, err = s.Pay(account.ID, 2_000_00, "auto")
+	if err != errDelivery || account.Balance != 9_000_00 {
+		t.Errorf("Pay(): must return delivery error, returned = %v, balance = %v", err, account.Balance)
+	}
+
+	s.SetCodeNotifier(nil)
+	_, err = s.Pay(account.ID, 2_000_00, "auto")
+	if err != ErrNoCodeNotifier || account.Balance != 9_000_00 {
+		t.Errorf("Pay(): must return ErrNoCodeNotifier, returned = %v, balance = %v", err, account.Balance)
+	}
+}
+
+func TestService_ExpireConfirmations_closedAccount(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s, _ := newConfirmationService(&now)
+	account, _, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payout, err := s.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := s.Pay(account.ID, 2_000_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Close(account.ID, payout.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//удержанная сумма возвращается и на закрытый счёт
+	now = now.Add(time.Minute)
+	expired, err := s.ExpireConfirmations()
+	if err != nil || expired != 1 {
+		t.Fatalf("ExpireConfirmations() = %d, error = %v", expired, err)
+	}
+	if payment.Status != types.PaymentStatusFail || account.Balance != 2_000_00 {
+		t.Errorf("ExpireConfirmations(): status = %v, balance = %v", payment.Status, account.Balance)
+	}
+
+	//отменённый платёж не должен снова ждать подтверждения после повторного экспорта
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := imported.FindPaymentByID(payment.ID)
+	if err != nil || got.Confirmation != nil || got.Status != types.PaymentStatusFail {
+		t.Errorf("Import(): payment = %v, error = %v", got, err)
+	}
+}
+
+func TestService_PayFromTemplate_confirmation(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s, _ := newConfirmationService(&now)
+	_, _, favorites := addFavorites(t, s, "electricity")
+	_, err := s.SetTemplate(favorites[0].ID, true, []types.TemplateField{{Name: "meter", Required: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var details map[string]string
+	s.SetCodeNotifier(func(account *types.Account, payment *types.Payment, code string) error {
+		details = payment.Details
+		return nil
+	})
+
+	_, err = s.PayFromTemplate(favorites[0].ID, types.TemplateOverrides{Amount: 2_000_00, Fields: map[string]string{"meter": "42"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details["meter"] != "42" {
+		t.Errorf("PayFromTemplate(): notifier must see the details, details = %v", details)
+	}
+}
+
+//wrongCode возвращает код, заведомо отличный от code
+func wrongCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
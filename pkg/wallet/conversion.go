@@ -0,0 +1,211 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrNoRateProvider = errors.New("exchange rate provider is not set")
+var ErrConversionNotAllowed = errors.New("conversion is allowed only between accounts of one owner in different currencies")
+var ErrInvalidSpread = errors.New("invalid conversion spread")
+
+//SetRateProvider задаёт источник курсов для Convert
+func (s *Service) SetRateProvider(provider RateProvider) {
+	s.rates = provider
+}
+
+//SetConversionSpread задаёт надбавку к курсу в базисных пунктах: 150 означает,
+//что клиент получает на 1,5% меньше, чем по курсу поставщика
+func (s *Service) SetConversionSpread(spread int64) error {
+	if spread < 0 || spread >= 10_000 {
+		return ErrInvalidSpread
+	}
+	s.conversionSpread = spread
+	return nil
+}
+
+//Convert обменивает amount в валюте счёта fromAccountID на валюту счёта toAccountID.
+//Оба счёта должны принадлежать одному телефону; результат округляется вниз
+func (s *Service) Convert(fromAccountID int64, toAccountID int64, amount types.Money) (*types.Conversion, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	from, err := s.FindAccountByID(fromAccountID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.FindAccountByID(toAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if from.Phone != to.Phone || from.Currency == to.Currency {
+		return nil, ErrConversionNotAllowed
+	}
+	for _, account := range []*types.Account{from, to} {
+		err = checkActive(account)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.rates == nil {
+		return nil, ErrNoRateProvider
+	}
+	rate, err := s.rates.Rate(from.Currency, to.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("%w: %s/%s", ErrRateNotFound, from.Currency, to.Currency)
+	}
+
+	applied := applySpread(rate, s.conversionSpread)
+	converted, err := convertAmount(amount, from.Currency, to.Currency, applied)
+	if err != nil {
+		return nil, err
+	}
+	if converted <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	if from.Balance < amount {
+		return nil, ErrNotEnoughBalance
+	}
+	//обмен расходует деньги счёта так же, как платёж, и не обходит его лимиты
+	err = s.checkLimits(from, amount, "")
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkTier(from, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.credit(to, converted)
+	if err != nil {
+		return nil, err
+	}
+	from.Balance -= amount
+	conversion := &types.Conversion{
+		ID:            uuid.New().String(),
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		From:          from.Currency,
+		To:            to.Currency,
+		Amount:        amount,
+		Converted:     converted,
+		Rate:          rate,
+		Spread:        s.conversionSpread,
+		AppliedRate:   applied,
+		CreatedAt:     s.now(),
+	}
+	s.conversions = append(s.conversions, conversion)
+	return conversion, nil
+}
+
+//Conversions возвращает обмены, в которых участвовал счёт
+func (s *Service) Conversions(accountID int64) ([]*types.Conversion, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	conversions := make([]*types.Conversion, 0)
+	for _, conversion := range s.conversions {
+		if conversion.FromAccountID == accountID || conversion.ToAccountID == accountID {
+			conversions = append(conversions, conversion)
+		}
+	}
+	return conversions, nil
+}
+
+//applySpread уменьшает курс на надбавку в базисных пунктах. Произведение считается
+//в big.Int, чтобы не переполниться на большом курсе; результат не больше курса
+func applySpread(rate int64, spread int64) int64 {
+	applied := new(big.Int).Mul(big.NewInt(rate), big.NewInt(10_000-spread))
+	return applied.Quo(applied, big.NewInt(10_000)).Int64()
+}
+
+//convertAmount пересчитывает сумму в минимальных единицах с учётом числа знаков валют
+func convertAmount(amount types.Money, from types.Currency, to types.Currency, rate int64) (types.Money, error) {
+	fromExponent, ok := from.Exponent()
+	if !ok {
+		return 0, ErrUnsupportedCurrency
+	}
+	toExponent, ok := to.Exponent()
+	if !ok {
+		return 0, ErrUnsupportedCurrency
+	}
+
+	numerator := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(rate))
+	numerator.Mul(numerator, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(toExponent)), nil))
+	denominator := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(fromExponent)), nil)
+	denominator.Mul(denominator, big.NewInt(RateScale))
+	result := numerator.Quo(numerator, denominator)
+	if !result.IsInt64() {
+		return 0, fmt.Errorf("conversion of %d %s overflows", amount, from)
+	}
+	return types.Money(result.Int64()), nil
+}
+
+func (s *Service) exportConversions(dir string) error {
+	records := make([][]string, 0, len(s.conversions))
+	for _, conversion := range s.conversions {
+		records = append(records, []string{
+			conversion.ID,
+			strconv.FormatInt(conversion.FromAccountID, 10),
+			strconv.FormatInt(conversion.ToAccountID, 10),
+			string(conversion.From),
+			string(conversion.To),
+			strconv.FormatInt(int64(conversion.Amount), 10),
+			strconv.FormatInt(int64(conversion.Converted), 10),
+			strconv.FormatInt(conversion.Rate, 10),
+			strconv.FormatInt(conversion.Spread, 10),
+			strconv.FormatInt(conversion.AppliedRate, 10),
+			strconv.FormatInt(conversion.CreatedAt.Unix(), 10),
+		})
+	}
+	return writeDump(dir+"/conversions.dump", records)
+}
+
+func (s *Service) importConversions(dir string) error {
+	records, err := readDump(dir + "/conversions.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 11 {
+			err = fmt.Errorf("conversions.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		//все поля, кроме id и валют, - целые числа
+		numbers := make(map[int]int64)
+		for _, i := range []int{1, 2, 5, 6, 7, 8, 9, 10} {
+			numbers[i], err = strconv.ParseInt(splits[i], 10, 64)
+			if err != nil {
+				log.Print(err)
+				return err
+			}
+		}
+		conversion := &types.Conversion{
+			ID:            splits[0],
+			FromAccountID: numbers[1],
+			ToAccountID:   numbers[2],
+			From:          types.Currency(splits[3]),
+			To:            types.Currency(splits[4]),
+			Amount:        types.Money(numbers[5]),
+			Converted:     types.Money(numbers[6]),
+			Rate:          numbers[7],
+			Spread:        numbers[8],
+			AppliedRate:   numbers[9],
+			CreatedAt:     time.Unix(numbers[10], 0),
+		}
+		s.conversions = append(s.conversions, conversion)
+	}
+	return nil
+}
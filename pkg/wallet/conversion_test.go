@@ -0,0 +1,164 @@
+package wallet
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestService_Convert(t *testing.T) {
+	s := newTestService()
+	s.SetClock(func() time.Time { return time.Date(2024, time.August, 1, 9, 0, 0, 0, time.Local) })
+	tjs, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usd, err := s.RegisterAccountInCurrency("+992000000001", types.USD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jpy, err := s.RegisterAccountInCurrency("+992000000001", types.JPY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.RegisterAccountInCurrency("+992000000002", types.USD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(usd.ID, 200_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Convert(usd.ID, tjs.ID, 100_00)
+	if err != ErrNoRateProvider {
+		t.Errorf("Convert(): must return ErrNoRateProvider, returned = %v", err)
+	}
+	rates := NewStaticRates()
+	rates.Set(types.USD, types.TJS, 10_950_000)
+	rates.Set(types.USD, types.JPY, 150_500_000)
+	s.SetRateProvider(rates)
+	err = s.SetConversionSpread(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Convert(tjs.ID, other.ID, 100_00)
+	if err != ErrConversionNotAllowed {
+		t.Errorf("Convert(): other owner must return ErrConversionNotAllowed, returned = %v", err)
+	}
+	_, err = s.Convert(tjs.ID, jpy.ID, 100_00)
+	if !errors.Is(err, ErrRateNotFound) {
+		t.Errorf("Convert(): must return ErrRateNotFound, returned = %v", err)
+	}
+
+	conversion, err := s.Convert(usd.ID, tjs.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conversion.Converted != 1084_05 || conversion.Rate != 10_950_000 || conversion.AppliedRate != 10_840_500 || conversion.Spread != 100 {
+		t.Errorf("Convert(): conversion = %v", conversion)
+	}
+	if usd.Balance != 100_00 || tjs.Balance != 1084_05 {
+		t.Errorf("Convert(): balances = %v, %v", usd.Balance, tjs.Balance)
+	}
+
+	s.SetConversionSpread(0)
+	conversion, err = s.Convert(usd.ID, jpy.ID, 1_00)
+	if err != nil || conversion.Converted != 150 {
+		t.Errorf("Convert(): JPY has no minor units, conversion = %v, error = %v", conversion, err)
+	}
+	conversion, err = s.Convert(tjs.ID, usd.ID, 1000_00)
+	if err != nil || conversion.Converted != 91_32 {
+		t.Errorf("Convert(): inverse rate, conversion = %v, error = %v", conversion, err)
+	}
+	_, err = s.Convert(usd.ID, tjs.ID, 1000_00)
+	if err != ErrNotEnoughBalance {
+		t.Errorf("Convert(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conversions, err := imported.Conversions(usd.ID)
+	if err != nil || len(conversions) != 3 || *conversions[0] != *s.conversions[0] {
+		t.Errorf("Import(): conversions = %v, error = %v", conversions, err)
+	}
+}
+
+func TestService_Convert_limits(t *testing.T) {
+	s := newTestService()
+	s.SetClock(func() time.Time { return time.Date(2024, time.August, 1, 9, 0, 0, 0, time.Local) })
+	tjs, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usd, err := s.RegisterAccountInCurrency("+992000000001", types.USD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(usd.ID, 200_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates := NewStaticRates()
+	rates.Set(types.USD, types.TJS, 10_950_000)
+	s.SetRateProvider(rates)
+	err = s.SetLimits(usd.ID, types.Limits{Daily: 150_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Convert(usd.ID, tjs.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Convert(usd.ID, tjs.ID, 100_00)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Remaining != 50_00 {
+		t.Errorf("Convert(): conversions must count toward the daily limit, returned = %v", err)
+	}
+	_, err = s.Pay(usd.ID, 60_00, "auto")
+	if !errors.As(err, &limitErr) {
+		t.Errorf("Pay(): conversions must count toward the daily limit, returned = %v", err)
+	}
+	if usd.Balance != 100_00 {
+		t.Errorf("Convert(): balance = %v", usd.Balance)
+	}
+}
+
+func TestLoadRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.txt")
+	err := os.WriteFile(path, []byte("USD;TJS;10.95\nEUR;TJS;11.8\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates, err := LoadRates(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rate, err := rates.Rate(types.EUR, types.TJS)
+	if err != nil || rate != 11_800_000 {
+		t.Errorf("Rate(): rate = %v, error = %v", rate, err)
+	}
+
+	err = os.WriteFile(path, []byte("USD;TJS;10.9500001\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadRates(path)
+	if err == nil {
+		t.Error("LoadRates(): too precise rate must fail")
+	}
+}
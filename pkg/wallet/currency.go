@@ -0,0 +1,45 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+var ErrCurrencyMismatch = errors.New("currency does not match account currency")
+
+//DepositInCurrency пополняет счёт, проверяя, что сумма указана в валюте счёта
+func (s *Service) DepositInCurrency(accountID int64, amount types.Money, currency types.Currency) error {
+	err := s.CheckCurrency(accountID, currency)
+	if err != nil {
+		return err
+	}
+	return s.Deposit(accountID, amount)
+}
+
+//PayInCurrency выполняет платёж, проверяя, что сумма указана в валюте счёта.
+//Платежи в другой валюте требуют явной конвертации
+func (s *Service) PayInCurrency(accountID int64, amount types.Money, currency types.Currency, category types.PaymentCategory) (*types.Payment, error) {
+	err := s.CheckCurrency(accountID, currency)
+	if err != nil {
+		return nil, err
+	}
+	return s.Pay(accountID, amount, category)
+}
+
+//CheckCurrency проверяет, что валюта известна и совпадает с валютой счёта
+func (s *Service) CheckCurrency(accountID int64, currency types.Currency) error {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	_, ok := currency.Exponent()
+	if !ok {
+		return ErrUnsupportedCurrency
+	}
+	if account.Currency != currency {
+		return ErrCurrencyMismatch
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package wallet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestService_RegisterAccountInCurrency(t *testing.T) {
+	s := newTestService()
+	tjs, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usd, err := s.RegisterAccountInCurrency("+992000000001", types.USD)
+	if err != nil {
+		t.Fatalf("RegisterAccountInCurrency(): same phone in another currency, error = %v", err)
+	}
+	if tjs.Currency != types.TJS || usd.Currency != types.USD {
+		t.Errorf("RegisterAccountInCurrency(): currencies = %v, %v", tjs.Currency, usd.Currency)
+	}
+	_, err = s.RegisterAccountInCurrency("+992000000001", types.USD)
+	if err != ErrPhoneRegistered {
+		t.Errorf("RegisterAccountInCurrency(): must return ErrPhoneRegistered, returned = %v", err)
+	}
+	_, err = s.RegisterAccountInCurrency("+992000000002", "XXX")
+	if err != ErrUnsupportedCurrency {
+		t.Errorf("RegisterAccountInCurrency(): must return ErrUnsupportedCurrency, returned = %v", err)
+	}
+
+	err = s.DepositInCurrency(usd.ID, 100_00, types.TJS)
+	if err != ErrCurrencyMismatch {
+		t.Errorf("DepositInCurrency(): must return ErrCurrencyMismatch, returned = %v", err)
+	}
+	err = s.DepositInCurrency(usd.ID, 100_00, types.USD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.PayInCurrency(usd.ID, 10_00, types.TJS, "cafe")
+	if err != ErrCurrencyMismatch {
+		t.Errorf("PayInCurrency(): must return ErrCurrencyMismatch, returned = %v", err)
+	}
+	payment, err := s.PayInCurrency(usd.ID, 10_00, types.USD, "cafe")
+	if err != nil || payment.Currency != types.USD {
+		t.Errorf("PayInCurrency(): payment = %v, error = %v", payment, err)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.ExportToFile(filepath.Join(dir, "accounts.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, _ := imported.FindAccountByID(usd.ID)
+	got, _ := imported.FindPaymentByID(payment.ID)
+	if account.Currency != types.USD || got.Currency != types.USD {
+		t.Errorf("Import(): account = %v, payment = %v", account, got)
+	}
+	fromFile := newTestService()
+	err = fromFile.ImportFromFile(filepath.Join(dir, "accounts.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, _ = fromFile.FindAccountByID(usd.ID)
+	if account.Currency != types.USD {
+		t.Errorf("ImportFromFile(): account = %v", account)
+	}
+}
+
+func TestService_Import_defaultCurrency(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "accounts.dump"), []byte("1;+992000000001;100\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "payments.dump"), []byte("p1;1;10;cafe;OK\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestService()
+	err = s.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, _ := s.FindAccountByID(1)
+	payment, _ := s.FindPaymentByID("p1")
+	if account.Currency != types.DefaultCurrency || payment.Currency != types.DefaultCurrency {
+		t.Errorf("Import(): account = %v, payment = %v", account, payment)
+	}
+}
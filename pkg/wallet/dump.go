@@ -0,0 +1,88 @@
+package wallet
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//writeDump записывает строки дампа, по одной записи на строку, поля разделены ';'.
+//Для пустого списка файл удаляется, чтобы импорт не поднял старые записи
+func writeDump(path string, records [][]string) error {
+	if len(records) == 0 {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			log.Print(err)
+			return err
+		}
+		return nil
+	}
+
+	str := ""
+	for _, record := range records {
+		str += strings.Join(record, ";") + "\n"
+	}
+	err := os.WriteFile(path, []byte(str), 0666)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+	return nil
+}
+
+//readDump читает записи дампа; отсутствующий файл означает отсутствие записей
+func readDump(path string) ([][]string, error) {
+	file, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, err
+	}
+
+	records := make([][]string, 0)
+	for _, line := range strings.Split(string(file), "\n") {
+		if line == "" {
+			continue
+		}
+		records = append(records, strings.Split(line, ";"))
+	}
+	return records, nil
+}
+
+//escape экранирует произвольный текст, чтобы он не ломал разметку дампа
+func escape(s string) string {
+	return url.QueryEscape(s)
+}
+
+func unescape(s string) (string, error) {
+	return url.QueryUnescape(s)
+}
+
+//formatTime сохраняет время в дампе в RFC 3339 вместе со смещением часового пояса;
+//пустая строка означает нулевое время
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+//parseTime читает время из дампа; старые дампы хранят секунды Unix
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if !strings.Contains(s, "T") {
+		unix, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
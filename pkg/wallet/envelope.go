@@ -0,0 +1,231 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/Behzod01/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrEnvelopeNotFound = errors.New("envelope not found")
+var ErrEnvelopeNameEmpty = errors.New("envelope name is empty")
+var ErrEnvelopeNameTaken = errors.New("envelope name already used")
+
+//CreateEnvelope создаёт пустой конверт в счёте; названия конвертов счёта не повторяются
+func (s *Service) CreateEnvelope(accountID int64, name string) (*types.Envelope, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkOpen(account)
+	if err != nil {
+		return nil, err
+	}
+	name, err = s.checkEnvelopeName(accountID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := &types.Envelope{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Name:      name,
+	}
+	s.envelopes = append(s.envelopes, envelope)
+	return envelope, nil
+}
+
+func (s *Service) FindEnvelopeByID(envelopeID string) (*types.Envelope, error) {
+	for _, envelope := range s.envelopes {
+		if envelope.ID == envelopeID {
+			return envelope, nil
+		}
+	}
+	return nil, ErrEnvelopeNotFound
+}
+
+//EnvelopesByAccount возвращает конверты счёта в порядке создания
+func (s *Service) EnvelopesByAccount(accountID int64) ([]*types.Envelope, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	envelopes := make([]*types.Envelope, 0)
+	for _, envelope := range s.envelopes {
+		if envelope.AccountID == accountID {
+			envelopes = append(envelopes, envelope)
+		}
+	}
+	return envelopes, nil
+}
+
+//MoveToEnvelope переносит сумму с основного баланса счёта в конверт
+func (s *Service) MoveToEnvelope(envelopeID string, amount types.Money) error {
+	envelope, account, err := s.envelopeAccount(envelopeID, amount)
+	if err != nil {
+		return err
+	}
+	if account.Balance < amount {
+		return ErrNotEnoughBalance
+	}
+	err = addMoney(&envelope.Balance, amount)
+	if err != nil {
+		return err
+	}
+	account.Balance -= amount
+	s.checkGoal(envelope)
+	return nil
+}
+
+//MoveFromEnvelope возвращает сумму из конверта на основной баланс счёта
+func (s *Service) MoveFromEnvelope(envelopeID string, amount types.Money) error {
+	envelope, account, err := s.envelopeAccount(envelopeID, amount)
+	if err != nil {
+		return err
+	}
+	if envelope.Balance < amount {
+		return ErrNotEnoughBalance
+	}
+	//остаток считается вместе с конвертами, поэтому сумма сначала уходит из конверта
+	envelope.Balance -= amount
+	err = s.credit(account, amount)
+	if err != nil {
+		envelope.Balance += amount
+		return err
+	}
+	return nil
+}
+
+//PayFromEnvelope выполняет платёж, списывая сумму и комиссию с конверта.
+//Лимиты счёта действуют так же, как для обычных платежей
+func (s *Service) PayFromEnvelope(envelopeID string, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	envelope, err := s.FindEnvelopeByID(envelopeID)
+	if err != nil {
+		return nil, err
+	}
+	return s.pay(envelope.AccountID, amount, category, envelope, nil)
+}
+
+//RemoveEnvelope удаляет конверт, возвращая его остаток на основной баланс
+func (s *Service) RemoveEnvelope(envelopeID string) error {
+	for i, envelope := range s.envelopes {
+		if envelope.ID != envelopeID {
+			continue
+		}
+		account, err := s.FindAccountByID(envelope.AccountID)
+		if err != nil {
+			return err
+		}
+		balance := envelope.Balance
+		envelope.Balance = 0
+		err = s.credit(account, balance)
+		if err != nil {
+			envelope.Balance = balance
+			return err
+		}
+		s.envelopes = append(s.envelopes[:i], s.envelopes[i+1:]...)
+		s.removeGoal(envelopeID)
+		return nil
+	}
+	return ErrEnvelopeNotFound
+}
+
+func (s *Service) envelopeAccount(envelopeID string, amount types.Money) (*types.Envelope, *types.Account, error) {
+	if amount <= 0 {
+		return nil, nil, ErrAmountMustBePositive
+	}
+	envelope, err := s.FindEnvelopeByID(envelopeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	account, err := s.FindAccountByID(envelope.AccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = checkActive(account)
+	if err != nil {
+		return nil, nil, err
+	}
+	return envelope, account, nil
+}
+
+//creditPayment возвращает сумму по платежу туда, откуда он был оплачен:
+//в конверт, а если конверт уже удалён - на основной баланс
+func (s *Service) creditPayment(payment *types.Payment, account *types.Account, amount types.Money) error {
+	envelope, err := s.FindEnvelopeByID(payment.EnvelopeID)
+	if err == nil {
+		err = addMoney(&envelope.Balance, amount)
+		if err != nil {
+			return err
+		}
+		s.checkGoal(envelope)
+		return nil
+	}
+	return addMoney(&account.Balance, amount)
+}
+
+func (s *Service) checkEnvelopeName(accountID int64, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrEnvelopeNameEmpty
+	}
+	for _, envelope := range s.envelopes {
+		if envelope.AccountID == accountID && strings.EqualFold(envelope.Name, name) {
+			return "", ErrEnvelopeNameTaken
+		}
+	}
+	return name, nil
+}
+
+func (s *Service) exportEnvelopes(dir string) error {
+	records := make([][]string, 0, len(s.envelopes))
+	for _, envelope := range s.envelopes {
+		records = append(records, []string{
+			envelope.ID,
+			strconv.FormatInt(envelope.AccountID, 10),
+			escape(envelope.Name),
+			strconv.FormatInt(int64(envelope.Balance), 10),
+		})
+	}
+	return writeDump(dir+"/envelopes.dump", records)
+}
+
+func (s *Service) importEnvelopes(dir string) error {
+	records, err := readDump(dir + "/envelopes.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 4 {
+			err = fmt.Errorf("envelopes.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		accountID, err := strconv.ParseInt(splits[1], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		name, err := unescape(splits[2])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		balance, err := strconv.ParseInt(splits[3], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		s.envelopes = append(s.envelopes, &types.Envelope{
+			ID:        splits[0],
+			AccountID: accountID,
+			Name:      name,
+			Balance:   types.Money(balance),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package wallet
+
+import (
+	"testing"
+)
+
+func TestService_Envelopes(t *testing.T) {
+	s := newTestService()
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := s.CreateEnvelope(account.ID, "vacation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.CreateEnvelope(account.ID, " Vacation")
+	if err != ErrEnvelopeNameTaken {
+		t.Errorf("CreateEnvelope(): must return ErrEnvelopeNameTaken, returned = %v", err)
+	}
+
+	err = s.MoveToEnvelope(envelope.ID, 1_000_01)
+	if err != ErrNotEnoughBalance {
+		t.Errorf("MoveToEnvelope(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	err = s.MoveToEnvelope(envelope.ID, 600_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.MoveFromEnvelope(envelope.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 500_00 || envelope.Balance != 500_00 {
+		t.Errorf("Move(): balance = %v, envelope = %v", account.Balance, envelope.Balance)
+	}
+
+	payment, err := s.PayFromEnvelope(envelope.ID, 200_00, "travel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.EnvelopeID != envelope.ID || account.Balance != 500_00 || envelope.Balance != 300_00 {
+		t.Errorf("PayFromEnvelope(): payment = %v, balance = %v, envelope = %v", payment, account.Balance, envelope.Balance)
+	}
+	_, err = s.PayFromEnvelope(envelope.ID, 300_01, "travel")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("PayFromEnvelope(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+
+	repeated, err := s.Repeat(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repeated.EnvelopeID != envelope.ID || envelope.Balance != 100_00 {
+		t.Errorf("Repeat(): payment = %v, envelope = %v", repeated, envelope.Balance)
+	}
+	_, err = s.Refund(repeated.ID, 50_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 500_00 || envelope.Balance != 350_00 {
+		t.Errorf("Reject(): money must return to the envelope, balance = %v, envelope = %v", account.Balance, envelope.Balance)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelopes, err := imported.EnvelopesByAccount(account.ID)
+	if err != nil || len(envelopes) != 1 || envelopes[0].Balance != 350_00 || envelopes[0].Name != "vacation" {
+		t.Errorf("Import(): envelopes = %v, error = %v", envelopes, err)
+	}
+	got, _ := imported.FindPaymentByID(repeated.ID)
+	if got.EnvelopeID != envelope.ID {
+		t.Errorf("Import(): payment = %v", got)
+	}
+
+	err = s.RemoveEnvelope(envelope.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 850_00 {
+		t.Errorf("RemoveEnvelope(): balance = %v", account.Balance)
+	}
+	err = s.Reject(repeated.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 1_000_00 {
+		t.Errorf("Reject(): removed envelope must credit main balance, balance = %v", account.Balance)
+	}
+
+	//повторный экспорт в тот же каталог не должен оставить удалённый конверт
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported = newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelopes, _ = imported.EnvelopesByAccount(account.ID)
+	got, _ = imported.FindPaymentByID(repeated.ID)
+	if len(envelopes) != 0 || got.EnvelopeID != envelope.ID {
+		t.Errorf("Import(): removed envelope restored, envelopes = %v", envelopes)
+	}
+	restored, _ := imported.FindAccountByID(account.ID)
+	if restored.Balance != 1_000_00 {
+		t.Errorf("Import(): balance = %v", restored.Balance)
+	}
+}
@@ -0,0 +1,129 @@
+package wallet
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+var ErrFavoriteNameEmpty = errors.New("favorite name is empty")
+var ErrFavoriteNameTaken = errors.New("favorite name already used")
+
+//FavoritesByAccount возвращает избранное счёта в порядке, заданном пользователем
+func (s *Service) FavoritesByAccount(accountID int64) ([]*types.Favorite, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.accountFavorites(accountID), nil
+}
+
+//UpdateFavorite меняет название и сумму избранного
+func (s *Service) UpdateFavorite(favoriteID string, name string, amount types.Money) (*types.Favorite, error) {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	name, err = s.checkFavoriteName(favorite.AccountID, favorite.ID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	favorite.Name = name
+	favorite.Amount = amount
+	return favorite, nil
+}
+
+//RemoveFavorite удаляет избранное и отключает его расписания
+func (s *Service) RemoveFavorite(favoriteID string) error {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return err
+	}
+
+	favorites := s.favorites[:0]
+	for _, fav := range s.favorites {
+		if fav.ID != favoriteID {
+			favorites = append(favorites, fav)
+		}
+	}
+	s.favorites = favorites
+
+	for _, schedule := range s.schedules {
+		if schedule.FavoriteID == favoriteID {
+			schedule.Active = false
+		}
+	}
+	s.renumberFavorites(s.accountFavorites(favorite.AccountID))
+	return nil
+}
+
+//MoveFavorite переставляет избранное на указанную позицию в списке счёта;
+//позиция за пределами списка означает его начало или конец
+func (s *Service) MoveFavorite(favoriteID string, position int) error {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return err
+	}
+
+	favorites := s.accountFavorites(favorite.AccountID)
+	others := make([]*types.Favorite, 0, len(favorites))
+	for _, fav := range favorites {
+		if fav.ID != favoriteID {
+			others = append(others, fav)
+		}
+	}
+	if position < 0 {
+		position = 0
+	}
+	if position > len(others) {
+		position = len(others)
+	}
+
+	ordered := make([]*types.Favorite, 0, len(favorites))
+	ordered = append(ordered, others[:position]...)
+	ordered = append(ordered, favorite)
+	ordered = append(ordered, others[position:]...)
+	s.renumberFavorites(ordered)
+	return nil
+}
+
+//accountFavorites возвращает избранное счёта, упорядоченное по позиции
+func (s *Service) accountFavorites(accountID int64) []*types.Favorite {
+	favorites := make([]*types.Favorite, 0)
+	for _, favorite := range s.favorites {
+		if favorite.AccountID == accountID {
+			favorites = append(favorites, favorite)
+		}
+	}
+	sort.SliceStable(favorites, func(i, j int) bool {
+		return favorites[i].Position < favorites[j].Position
+	})
+	return favorites
+}
+
+func (s *Service) renumberFavorites(favorites []*types.Favorite) {
+	for i, favorite := range favorites {
+		favorite.Position = i
+	}
+}
+
+//checkFavoriteName проверяет, что название не пустое и не занято другим избранным счёта
+//(без учёта регистра); возвращает название без пробелов по краям
+func (s *Service) checkFavoriteName(accountID int64, favoriteID string, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrFavoriteNameEmpty
+	}
+	for _, favorite := range s.favorites {
+		if favorite.AccountID == accountID && favorite.ID != favoriteID && strings.EqualFold(favorite.Name, name) {
+			return "", ErrFavoriteNameTaken
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,187 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func addFavorites(t *testing.T, s *testService, names ...string) (*types.Account, *types.Payment, []*types.Favorite) {
+	t.Helper()
+	account, payments, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	favorites := make([]*types.Favorite, len(names))
+	for i, name := range names {
+		favorites[i], err = s.FavoritePayment(payments[0].ID, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return account, payments[0], favorites
+}
+
+func favoriteNames(favorites []*types.Favorite) []string {
+	names := make([]string, len(favorites))
+	for i, favorite := range favorites {
+		names[i] = favorite.Name
+	}
+	return names
+}
+
+func TestService_FavoritePayment_nameTaken(t *testing.T) {
+	s := newTestService()
+	_, payment, _ := addFavorites(t, s, "mobile")
+
+	_, err := s.FavoritePayment(payment.ID, " Mobile ")
+	if err != ErrFavoriteNameTaken {
+		t.Errorf("FavoritePayment(): must return ErrFavoriteNameTaken, returned = %v", err)
+	}
+	_, err = s.FavoritePayment(payment.ID, " ")
+	if err != ErrFavoriteNameEmpty {
+		t.Errorf("FavoritePayment(): must return ErrFavoriteNameEmpty, returned = %v", err)
+	}
+}
+
+func TestService_UpdateFavorite(t *testing.T) {
+	s := newTestService()
+	_, _, favorites := addFavorites(t, s, "mobile", "internet")
+
+	favorite, err := s.UpdateFavorite(favorites[0].ID, "phone", 50_00)
+	if err != nil {
+		t.Fatalf("UpdateFavorite(): error = %v", err)
+	}
+	if favorite.Name != "phone" || favorite.Amount != 50_00 {
+		t.Errorf("UpdateFavorite(): favorite = %v", favorite)
+	}
+
+	_, err = s.UpdateFavorite(favorites[0].ID, "internet", 50_00)
+	if err != ErrFavoriteNameTaken {
+		t.Errorf("UpdateFavorite(): must return ErrFavoriteNameTaken, returned = %v", err)
+	}
+	_, err = s.UpdateFavorite(favorites[0].ID, "phone", 0)
+	if err != ErrAmountMustBePositive {
+		t.Errorf("UpdateFavorite(): must return ErrAmountMustBePositive, returned = %v", err)
+	}
+	_, err = s.UpdateFavorite("unknown", "phone", 1)
+	if err != ErrFavoriteNotFound {
+		t.Errorf("UpdateFavorite(): must return ErrFavoriteNotFound, returned = %v", err)
+	}
+}
+
+func TestService_MoveFavorite(t *testing.T) {
+	s := newTestService()
+	account, _, favorites := addFavorites(t, s, "a", "b", "c")
+
+	err := s.MoveFavorite(favorites[2].ID, 0)
+	if err != nil {
+		t.Fatalf("MoveFavorite(): error = %v", err)
+	}
+	list, err := s.FavoritesByAccount(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := favoriteNames(list); got[0] != "c" || got[1] != "a" || got[2] != "b" {
+		t.Errorf("MoveFavorite(): order = %v", got)
+	}
+
+	err = s.MoveFavorite(favorites[2].ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ = s.FavoritesByAccount(account.ID)
+	if got := favoriteNames(list); got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Errorf("MoveFavorite(): order = %v", got)
+	}
+}
+
+func TestService_RemoveFavorite(t *testing.T) {
+	s := newTestService()
+	account, payment, favorites := addFavorites(t, s, "a", "b", "c")
+	schedule, err := s.SchedulePayment(favorites[1].ID, types.ScheduleRule{Kind: types.ScheduleDaily, Start: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.RemoveFavorite(favorites[1].ID)
+	if err != nil {
+		t.Fatalf("RemoveFavorite(): error = %v", err)
+	}
+	_, err = s.FindFavoriteByID(favorites[1].ID)
+	if err != ErrFavoriteNotFound {
+		t.Errorf("RemoveFavorite(): favorite must be removed, error = %v", err)
+	}
+	if schedule.Active {
+		t.Errorf("RemoveFavorite(): schedule must be cancelled")
+	}
+
+	list, _ := s.FavoritesByAccount(account.ID)
+	if len(list) != 2 || list[0].Position != 0 || list[1].Position != 1 || list[1].Name != "c" {
+		t.Errorf("RemoveFavorite(): favorites = %v", list)
+	}
+
+	_, err = s.FavoritePayment(payment.ID, "b")
+	if err != nil {
+		t.Errorf("FavoritePayment(): name of removed favorite must be free, error = %v", err)
+	}
+
+	dir := t.TempDir()
+	err = s.MoveFavorite(favorites[2].ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ = imported.FavoritesByAccount(account.ID)
+	if got := favoriteNames(list); len(got) != 3 || got[0] != "c" || got[1] != "a" || got[2] != "b" {
+		t.Errorf("Import(): order = %v", got)
+	}
+}
+
+func TestService_Export_favorites(t *testing.T) {
+	s := newTestService()
+	account, _, favorites := addFavorites(t, s, "дом; дача\nмама")
+
+	dir := t.TempDir()
+	err := s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := imported.FavoritesByAccount(account.ID)
+	if len(list) != 1 || list[0].Name != favorites[0].Name {
+		t.Errorf("Import(): favorites = %v", list)
+	}
+
+	//удалённое последнее избранное не должно вернуться после перезапуска
+	err = s.RemoveFavorite(favorites[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported = newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ = imported.FavoritesByAccount(account.ID)
+	if len(list) != 0 {
+		t.Errorf("Import(): removed favorite restored, favorites = %v", list)
+	}
+}
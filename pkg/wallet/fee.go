@@ -0,0 +1,90 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+var ErrInvalidFeeRule = errors.New("invalid fee rule")
+
+//SetFeeRule задаёт комиссию для категории платежей, заменяя прежнее правило.
+//Правило категории действует и на вложенные в неё категории, если у них нет своего.
+//Отрицательные значения и Min больше Max недопустимы: комиссия не может зачислять деньги
+func (s *Service) SetFeeRule(rule types.FeeRule) error {
+	if rule.Fixed < 0 || rule.Percent < 0 || rule.Min < 0 || rule.Max < 0 || rule.FreeUpTo < 0 {
+		return ErrInvalidFeeRule
+	}
+	if rule.Max > 0 && rule.Min > rule.Max {
+		return ErrInvalidFeeRule
+	}
+	category, err := s.resolveCategory(rule.Category)
+	if err != nil {
+		return err
+	}
+	rule.Category = category
+	if s.feeRules == nil {
+		s.feeRules = make(map[types.PaymentCategory]types.FeeRule)
+	}
+	s.feeRules[category] = rule
+	return nil
+}
+
+//RemoveFeeRule отменяет комиссию для категории
+func (s *Service) RemoveFeeRule(category types.PaymentCategory) {
+	delete(s.feeRules, s.canonicalCategory(category))
+}
+
+//Fee рассчитывает комиссию, которая будет списана за платёж.
+//Комиссию, которая не помещается в Money, заплатить нельзя: возвращается MaxMoney
+func (s *Service) Fee(amount types.Money, category types.PaymentCategory) types.Money {
+	fee, err := s.fee(amount, category)
+	if err != nil {
+		return types.MaxMoney
+	}
+	return fee
+}
+
+func (s *Service) fee(amount types.Money, category types.PaymentCategory) (types.Money, error) {
+	rule, ok := s.feeRule(category)
+	if !ok || amount <= rule.FreeUpTo {
+		return 0, nil
+	}
+
+	//процент округляется до ближайшей минимальной единицы, половина - до чётной
+	percent, err := amount.Percent(int64(rule.Percent))
+	if err != nil {
+		return 0, err
+	}
+	fee, err := rule.Fixed.Add(percent)
+	if err != nil {
+		return 0, err
+	}
+	if fee < rule.Min {
+		fee = rule.Min
+	}
+	if rule.Max > 0 && fee > rule.Max {
+		fee = rule.Max
+	}
+	return fee, nil
+}
+
+//feeRule ищет правило комиссии категории, а если его нет - ближайшего родителя
+func (s *Service) feeRule(category types.PaymentCategory) (types.FeeRule, bool) {
+	for _, code := range s.categoryPath(category) {
+		rule, ok := s.feeRules[code]
+		if ok {
+			return rule, true
+		}
+	}
+	return types.FeeRule{}, false
+}
+
+//refundFee возвращает долю комиссии, приходящуюся на возвращаемую сумму.
+//Последний возврат забирает остаток комиссии, чтобы не терять копейки на округлении
+func refundFee(payment *types.Payment, amount types.Money) (types.Money, error) {
+	if payment.Refunded+amount == payment.Amount {
+		return payment.Fee - payment.FeeRefunded, nil
+	}
+	return payment.Fee.MulDiv(int64(amount), int64(payment.Amount))
+}
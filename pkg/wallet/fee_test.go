@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestService_Fee(t *testing.T) {
+	s := newTestService()
+	s.SetFeeRule(types.FeeRule{Category: "mobile", Percent: 150, Min: 1_00, Max: 50_00, FreeUpTo: 10_00})
+	s.SetFeeRule(types.FeeRule{Category: "transfer", Fixed: 2_00, Percent: 100})
+
+	tests := []struct {
+		amount   types.Money
+		category types.PaymentCategory
+		want     types.Money
+	}{
+		{amount: 10_00, category: "mobile", want: 0},
+		{amount: 20_00, category: "mobile", want: 1_00},
+		{amount: 1_000_00, category: "mobile", want: 15_00},
+		{amount: 1_000_50, category: "mobile", want: 15_01},
+		{amount: 10_000_00, category: "mobile", want: 50_00},
+		{amount: 1_000_00, category: "transfer", want: 12_00},
+		{amount: 1_000_00, category: "auto", want: 0},
+	}
+	for _, test := range tests {
+		got := s.Fee(test.amount, test.category)
+		if got != test.want {
+			t.Errorf("Fee(%v, %v) = %v, want %v", test.amount, test.category, got, test.want)
+		}
+	}
+
+	s.RemoveFeeRule("mobile")
+	if fee := s.Fee(1_000_00, "mobile"); fee != 0 {
+		t.Errorf("Fee() after RemoveFeeRule = %v, want 0", fee)
+	}
+}
+
+func TestService_SetFeeRule_invalid(t *testing.T) {
+	s := newTestService()
+	rules := []types.FeeRule{
+		{Category: "auto", Fixed: -1},
+		{Category: "auto", Percent: -1},
+		{Category: "auto", Min: -1_00},
+		{Category: "auto", Max: -1},
+		{Category: "auto", FreeUpTo: -1},
+		{Category: "auto", Min: 2_00, Max: 1_00},
+	}
+	for _, rule := range rules {
+		err := s.SetFeeRule(rule)
+		if err != ErrInvalidFeeRule {
+			t.Errorf("SetFeeRule(%+v): must return ErrInvalidFeeRule, returned = %v", rule, err)
+		}
+	}
+	if fee := s.Fee(10_00, "auto"); fee != 0 {
+		t.Errorf("Fee(): invalid rule must not apply, fee = %d", fee)
+	}
+}
+
+func TestService_Pay_fee(t *testing.T) {
+	s := newTestService()
+	s.SetFeeRule(types.FeeRule{Category: "auto", Percent: 100})
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Pay(account.ID, 1_000_00, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("Pay(): fee must be covered by balance, error = %v", err)
+	}
+
+	payment, err := s.Pay(account.ID, 300_00, "auto")
+	if err != nil {
+		t.Fatalf("Pay(): error = %v", err)
+	}
+	if payment.Fee != 3_00 || account.Balance != 697_00 {
+		t.Errorf("Pay(): fee = %v, balance = %v", payment.Fee, account.Balance)
+	}
+
+	refund, err := s.Refund(payment.ID, 100_00)
+	if err != nil {
+		t.Fatalf("Refund(): error = %v", err)
+	}
+	if refund.Fee != 1_00 || account.Balance != 798_00 {
+		t.Errorf("Refund(): fee = %v, balance = %v", refund.Fee, account.Balance)
+	}
+
+	err = s.Reject(payment.ID)
+	if err != nil {
+		t.Fatalf("Reject(): error = %v", err)
+	}
+	if account.Balance != 1_000_00 {
+		t.Errorf("Reject(): remaining fee must be returned, balance = %v", account.Balance)
+	}
+}
+
+func TestService_Refund_feeRounding(t *testing.T) {
+	s := newTestService()
+	s.SetFeeRule(types.FeeRule{Category: "auto", Fixed: 1_00})
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := s.Pay(account.ID, 3_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = s.Refund(payment.ID, 1_00)
+		if err != nil {
+			t.Fatalf("Refund(): error = %v", err)
+		}
+	}
+	if payment.FeeRefunded != payment.Fee || account.Balance != 1_000_00 {
+		t.Errorf("Refund(): fee refunded = %v, balance = %v", payment.FeeRefunded, account.Balance)
+	}
+}
@@ -0,0 +1,234 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrGoalNotFound = errors.New("goal not found")
+var ErrGoalExists = errors.New("envelope already has a goal")
+
+//GoalCompletedHandler вызывается один раз, когда в конверте набирается сумма цели
+type GoalCompletedHandler func(goal types.Goal)
+
+//SetGoalCompletedHandler задаёт обработчик достижения целей; nil отключает уведомления
+func (s *Service) SetGoalCompletedHandler(handler GoalCompletedHandler) {
+	s.goalCompleted = handler
+}
+
+//CreateGoal ставит цель накопления для конверта; нулевой deadline означает цель без срока
+func (s *Service) CreateGoal(envelopeID string, target types.Money, deadline time.Time) (*types.Goal, error) {
+	if target <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	envelope, err := s.FindEnvelopeByID(envelopeID)
+	if err != nil {
+		return nil, err
+	}
+	if s.findGoalByEnvelope(envelopeID) != nil {
+		return nil, ErrGoalExists
+	}
+
+	goal := &types.Goal{
+		ID:         uuid.New().String(),
+		EnvelopeID: envelopeID,
+		AccountID:  envelope.AccountID,
+		Target:     target,
+		Deadline:   deadline,
+	}
+	s.goals = append(s.goals, goal)
+	s.checkGoal(envelope)
+	return goal, nil
+}
+
+func (s *Service) FindGoalByID(goalID string) (*types.Goal, error) {
+	for _, goal := range s.goals {
+		if goal.ID == goalID {
+			return goal, nil
+		}
+	}
+	return nil, ErrGoalNotFound
+}
+
+//SetGoalRoundUp включает округление платежей в пользу цели; нулевой unit отключает его.
+//Округление действует только для одной цели счёта, у остальных целей оно отключается
+func (s *Service) SetGoalRoundUp(goalID string, unit types.Money) error {
+	if unit < 0 {
+		return ErrAmountMustBePositive
+	}
+	goal, err := s.FindGoalByID(goalID)
+	if err != nil {
+		return err
+	}
+	if unit > 0 {
+		for _, other := range s.goals {
+			if other.AccountID == goal.AccountID {
+				other.RoundUp = 0
+			}
+		}
+	}
+	goal.RoundUp = unit
+	return nil
+}
+
+//GoalProgress возвращает, сколько накоплено по цели
+func (s *Service) GoalProgress(goalID string) (types.GoalProgress, error) {
+	goal, err := s.FindGoalByID(goalID)
+	if err != nil {
+		return types.GoalProgress{}, err
+	}
+	envelope, err := s.FindEnvelopeByID(goal.EnvelopeID)
+	if err != nil {
+		return types.GoalProgress{}, err
+	}
+
+	saved := envelope.Balance
+	remaining := goal.Target - saved
+	if remaining < 0 {
+		remaining = 0
+	}
+	percent := int(int64(saved) * 100 / int64(goal.Target))
+	if percent > 100 {
+		percent = 100
+	}
+	return types.GoalProgress{
+		GoalID:    goal.ID,
+		Target:    goal.Target,
+		Saved:     saved,
+		Remaining: remaining,
+		Percent:   percent,
+		Completed: goal.Completed,
+		Overdue:   !goal.Completed && !goal.Deadline.IsZero() && s.now().After(goal.Deadline),
+	}, nil
+}
+
+func (s *Service) findGoalByEnvelope(envelopeID string) *types.Goal {
+	for _, goal := range s.goals {
+		if goal.EnvelopeID == envelopeID {
+			return goal
+		}
+	}
+	return nil
+}
+
+func (s *Service) removeGoal(envelopeID string) {
+	for i, goal := range s.goals {
+		if goal.EnvelopeID == envelopeID {
+			s.goals = append(s.goals[:i], s.goals[i+1:]...)
+			return
+		}
+	}
+}
+
+//roundUp переносит в конверт цели разницу между платежом и ближайшей кратной суммой.
+//Если на основном балансе не хватает денег, округление пропускается
+func (s *Service) roundUp(account *types.Account, payment *types.Payment) {
+	for _, goal := range s.goals {
+		if goal.AccountID != account.ID || goal.RoundUp == 0 {
+			continue
+		}
+		envelope, err := s.FindEnvelopeByID(goal.EnvelopeID)
+		if err != nil {
+			return
+		}
+		difference := (goal.RoundUp - payment.Amount%goal.RoundUp) % goal.RoundUp
+		if difference == 0 || account.Balance < difference {
+			return
+		}
+		account.Balance -= difference
+		envelope.Balance += difference
+		s.checkGoal(envelope)
+		return
+	}
+}
+
+//checkGoal отмечает цель конверта достигнутой, когда баланс дошёл до суммы цели
+func (s *Service) checkGoal(envelope *types.Envelope) {
+	goal := s.findGoalByEnvelope(envelope.ID)
+	if goal == nil || goal.Completed || envelope.Balance < goal.Target {
+		return
+	}
+	goal.Completed = true
+	goal.CompletedAt = s.now()
+	if s.goalCompleted != nil {
+		s.goalCompleted(*goal)
+	}
+}
+
+func (s *Service) exportGoals(dir string) error {
+	records := make([][]string, 0, len(s.goals))
+	for _, goal := range s.goals {
+		records = append(records, []string{
+			goal.ID,
+			goal.EnvelopeID,
+			strconv.FormatInt(goal.AccountID, 10),
+			strconv.FormatInt(int64(goal.Target), 10),
+			formatTime(goal.Deadline),
+			strconv.FormatInt(int64(goal.RoundUp), 10),
+			strconv.FormatBool(goal.Completed),
+			formatTime(goal.CompletedAt),
+		})
+	}
+	return writeDump(dir+"/goals.dump", records)
+}
+
+func (s *Service) importGoals(dir string) error {
+	records, err := readDump(dir + "/goals.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 8 {
+			err = fmt.Errorf("goals.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		accountID, err := strconv.ParseInt(splits[2], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		target, err := strconv.ParseInt(splits[3], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		deadline, err := parseTime(splits[4])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		roundUp, err := strconv.ParseInt(splits[5], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		completed, err := strconv.ParseBool(splits[6])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		completedAt, err := parseTime(splits[7])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		s.goals = append(s.goals, &types.Goal{
+			ID:          splits[0],
+			EnvelopeID:  splits[1],
+			AccountID:   accountID,
+			Target:      types.Money(target),
+			Deadline:    deadline,
+			RoundUp:     types.Money(roundUp),
+			Completed:   completed,
+			CompletedAt: completedAt,
+		})
+	}
+	return nil
+}
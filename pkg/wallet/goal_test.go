@@ -0,0 +1,114 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestService_Goals_roundUp(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	completed := make([]types.Goal, 0)
+	s.SetGoalCompletedHandler(func(goal types.Goal) {
+		completed = append(completed, goal)
+	})
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := s.CreateEnvelope(account.ID, "bike")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goal, err := s.CreateGoal(envelope.ID, 100_00, now.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.CreateGoal(envelope.ID, 50_00, time.Time{})
+	if err != ErrGoalExists {
+		t.Errorf("CreateGoal(): must return ErrGoalExists, returned = %v", err)
+	}
+	err = s.SetGoalRoundUp(goal.ID, 10_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Pay(account.ID, 12_50, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Pay(account.ID, 20_00, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Balance != 7_50 || account.Balance != 1_000_00-12_50-20_00-7_50 {
+		t.Errorf("Pay(): envelope = %v, balance = %v", envelope.Balance, account.Balance)
+	}
+	_, err = s.PayFromEnvelope(envelope.ID, 1_50, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Balance != 6_00 {
+		t.Errorf("PayFromEnvelope(): must not round up, envelope = %v", envelope.Balance)
+	}
+
+	progress, err := s.GoalProgress(goal.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Saved != 6_00 || progress.Remaining != 94_00 || progress.Percent != 6 || progress.Completed {
+		t.Errorf("GoalProgress(): progress = %v", progress)
+	}
+
+	now = now.AddDate(0, 2, 0)
+	progress, _ = s.GoalProgress(goal.ID)
+	if !progress.Overdue {
+		t.Errorf("GoalProgress(): must be overdue, progress = %v", progress)
+	}
+
+	err = s.MoveToEnvelope(envelope.ID, 94_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != 1 || completed[0].ID != goal.ID || !goal.Completed {
+		t.Errorf("MoveToEnvelope(): completed = %v", completed)
+	}
+	err = s.MoveToEnvelope(envelope.ID, 1_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != 1 {
+		t.Errorf("MoveToEnvelope(): goal must complete once, completed = %v", completed)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := imported.FindGoalByID(goal.ID)
+	if err != nil || got.RoundUp != 10_00 || !got.Completed || !got.Deadline.Equal(goal.Deadline) || !got.CompletedAt.Equal(now) {
+		t.Errorf("Import(): goal = %v, error = %v", got, err)
+	}
+
+	err = s.RemoveEnvelope(envelope.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.FindGoalByID(goal.ID)
+	if err != ErrGoalNotFound {
+		t.Errorf("RemoveEnvelope(): goal must be removed, error = %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+//DefaultIdempotencyTTL - время, в течение которого повтор запроса с тем же ключом
+//возвращает первоначальный результат
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key reused with different parameters")
+
+//idempotencyRecord хранит результат операции, выполненной с ключом идемпотентности
+type idempotencyRecord struct {
+	key       string
+	request   string
+	paymentID string
+	err       error
+	createdAt time.Time
+}
+
+//SetIdempotencyTTL задаёт время жизни ключей идемпотентности
+func (s *Service) SetIdempotencyTTL(ttl time.Duration) {
+	s.idempotencyTTL = ttl
+}
+
+//PayIdempotent выполняет Pay один раз для ключа: повторный вызов с тем же ключом
+//возвращает первоначальный платёж или ошибку. Пустой ключ отключает проверку
+func (s *Service) PayIdempotent(key string, accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	if key == "" {
+		return s.Pay(accountID, amount, category)
+	}
+
+	request := fmt.Sprintf("pay:%d:%d:%s", accountID, amount, category)
+	record, err := s.findIdempotencyRecord(key, request)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		if record.err != nil {
+			return nil, record.err
+		}
+		return s.FindPaymentByID(record.paymentID)
+	}
+
+	payment, err := s.Pay(accountID, amount, category)
+	record = &idempotencyRecord{
+		key:       key,
+		request:   request,
+		err:       err,
+		createdAt: s.now(),
+	}
+	if payment != nil {
+		record.paymentID = payment.ID
+	}
+	s.idempotency = append(s.idempotency, record)
+	return payment, err
+}
+
+//DepositIdempotent выполняет Deposit один раз для ключа: повторный вызов с тем же ключом
+//возвращает первоначальную ошибку, не зачисляя средства снова. Пустой ключ отключает проверку
+func (s *Service) DepositIdempotent(key string, accountID int64, amount types.Money) error {
+	if key == "" {
+		return s.Deposit(accountID, amount)
+	}
+
+	request := fmt.Sprintf("deposit:%d:%d", accountID, amount)
+	record, err := s.findIdempotencyRecord(key, request)
+	if err != nil {
+		return err
+	}
+	if record != nil {
+		return record.err
+	}
+
+	err = s.Deposit(accountID, amount)
+	s.idempotency = append(s.idempotency, &idempotencyRecord{
+		key:       key,
+		request:   request,
+		err:       err,
+		createdAt: s.now(),
+	})
+	return err
+}
+
+//findIdempotencyRecord удаляет просроченные ключи и ищет запись по ключу
+func (s *Service) findIdempotencyRecord(key string, request string) (*idempotencyRecord, error) {
+	s.expireIdempotencyKeys()
+	for _, record := range s.idempotency {
+		if record.key != key {
+			continue
+		}
+		if record.request != request {
+			return nil, ErrIdempotencyKeyReused
+		}
+		return record, nil
+	}
+	return nil, nil
+}
+
+func (s *Service) expireIdempotencyKeys() {
+	ttl := s.idempotencyTTL
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	deadline := s.now().Add(-ttl)
+
+	records := s.idempotency[:0]
+	for _, record := range s.idempotency {
+		if record.createdAt.After(deadline) {
+			records = append(records, record)
+		}
+	}
+	s.idempotency = records
+}
+
+//limitErrorCode - код ошибки превышения лимита; поля LimitError сохраняются после него
+const limitErrorCode = "limit"
+
+//restoredError - восстановленная из дампа обёрнутая ошибка: текст сохраняется
+//как был, а errors.Is находит исходную ошибку
+type restoredError struct {
+	text string
+	err  error
+}
+
+func (e *restoredError) Error() string {
+	return e.text
+}
+
+func (e *restoredError) Unwrap() error {
+	return e.err
+}
+
+//errorCode возвращает код ошибки для дампа: код из errorCodes для ошибки, которую
+//оборачивает err, или поля LimitError; для прочих ошибок код пустой
+func errorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return strings.Join([]string{
+			limitErrorCode,
+			escape(string(limitErr.Kind)),
+			escape(string(limitErr.Category)),
+			escape(string(limitErr.Currency)),
+			strconv.FormatInt(int64(limitErr.Limit), 10),
+			strconv.FormatInt(int64(limitErr.Remaining), 10),
+		}, ":")
+	}
+	for known, code := range errorCodes {
+		if errors.Is(err, known) {
+			return code
+		}
+	}
+	return ""
+}
+
+//restoreError восстанавливает ошибку по тексту и коду. В старых дампах кода нет
+//или код совпадает с текстом ошибки, тогда ошибка ищется по тексту
+func restoreError(text string, code string) error {
+	if text == "" {
+		return nil
+	}
+	if code == "" {
+		code = text
+	}
+	if strings.HasPrefix(code, limitErrorCode+":") {
+		limitErr, err := restoreLimitError(code)
+		if err != nil {
+			//без полей лимита ошибка всё равно остаётся ErrLimitExceeded
+			log.Print(err)
+			return &restoredError{text: text, err: ErrLimitExceeded}
+		}
+		return limitErr
+	}
+	for known, knownCode := range errorCodes {
+		if knownCode != code && known.Error() != code {
+			continue
+		}
+		if text == known.Error() {
+			return known
+		}
+		return &restoredError{text: text, err: known}
+	}
+	return errors.New(text)
+}
+
+func restoreLimitError(code string) (*LimitError, error) {
+	splits := strings.Split(code, ":")
+	if len(splits) != 6 {
+		return nil, fmt.Errorf("idempotency.dump: wrong error code %s", code)
+	}
+	kind, err := unescape(splits[1])
+	if err != nil {
+		return nil, err
+	}
+	category, err := unescape(splits[2])
+	if err != nil {
+		return nil, err
+	}
+	currency, err := unescape(splits[3])
+	if err != nil {
+		return nil, err
+	}
+	limit, err := strconv.ParseInt(splits[4], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := strconv.ParseInt(splits[5], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &LimitError{
+		Kind:      types.LimitKind(kind),
+		Category:  types.PaymentCategory(category),
+		Currency:  types.Currency(currency),
+		Limit:     types.Money(limit),
+		Remaining: types.Money(remaining),
+	}, nil
+}
+
+func (s *Service) exportIdempotency(dir string) error {
+	s.expireIdempotencyKeys()
+	records := make([][]string, 0, len(s.idempotency))
+	for _, record := range s.idempotency {
+		errText := ""
+		if record.err != nil {
+			errText = record.err.Error()
+		}
+		records = append(records, []string{
+			escape(record.key),
+			escape(record.request),
+			record.paymentID,
+			strconv.FormatInt(record.createdAt.UnixNano(), 10),
+			escape(errText),
+			escape(errorCode(record.err)),
+		})
+	}
+	return writeDump(dir+"/idempotency.dump", records)
+}
+
+func (s *Service) importIdempotency(dir string) error {
+	records, err := readDump(dir + "/idempotency.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		//в старых дампах нет кода ошибки
+		if len(splits) != 5 && len(splits) != 6 {
+			err = fmt.Errorf("idempotency.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		key, err := unescape(splits[0])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		request, err := unescape(splits[1])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		createdAt, err := strconv.ParseInt(splits[3], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		errText, err := unescape(splits[4])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		code := ""
+		if len(splits) > 5 {
+			code, err = unescape(splits[5])
+			if err != nil {
+				log.Print(err)
+				return err
+			}
+		}
+		s.idempotency = append(s.idempotency, &idempotencyRecord{
+			key:       key,
+			request:   request,
+			paymentID: splits[2],
+			err:       restoreError(errText, code),
+			createdAt: time.Unix(0, createdAt),
+		})
+	}
+	s.expireIdempotencyKeys()
+	return nil
+}
@@ -0,0 +1,205 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestService_PayIdempotent_repeat(t *testing.T) {
+	s := newTestService()
+	account, _, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance := account.Balance
+
+	first, err := s.PayIdempotent("key-1", account.ID, 100, "auto")
+	if err != nil {
+		t.Fatalf("PayIdempotent(): error = %v", err)
+	}
+	second, err := s.PayIdempotent("key-1", account.ID, 100, "auto")
+	if err != nil {
+		t.Fatalf("PayIdempotent(): repeat error = %v", err)
+	}
+	if first != second {
+		t.Errorf("PayIdempotent(): repeat must return original payment, got %v, want %v", second, first)
+	}
+	if account.Balance != balance-100 {
+		t.Errorf("PayIdempotent(): balance debited twice, balance = %v", account.Balance)
+	}
+
+	_, err = s.PayIdempotent("key-1", account.ID, 200, "auto")
+	if err != ErrIdempotencyKeyReused {
+		t.Errorf("PayIdempotent(): must return ErrIdempotencyKeyReused, returned = %v", err)
+	}
+}
+
+func TestService_PayIdempotent_error(t *testing.T) {
+	s := newTestService()
+	account, _, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.PayIdempotent("key-1", account.ID, account.Balance+1, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Fatalf("PayIdempotent(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	err = s.Deposit(account.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.PayIdempotent("key-1", account.ID, account.Balance, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("PayIdempotent(): repeat must return original error, returned = %v", err)
+	}
+}
+
+func TestService_DepositIdempotent_expire(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestService()
+	s.SetClock(func() time.Time { return now })
+	s.SetIdempotencyTTL(time.Hour)
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = s.DepositIdempotent("key-1", account.ID, 100)
+		if err != nil {
+			t.Fatalf("DepositIdempotent(): error = %v", err)
+		}
+	}
+	if account.Balance != 100 {
+		t.Errorf("DepositIdempotent(): balance = %v, want 100", account.Balance)
+	}
+
+	now = now.Add(2 * time.Hour)
+	err = s.DepositIdempotent("key-1", account.ID, 100)
+	if err != nil {
+		t.Fatalf("DepositIdempotent(): error = %v", err)
+	}
+	if account.Balance != 200 {
+		t.Errorf("DepositIdempotent(): expired key must execute again, balance = %v", account.Balance)
+	}
+}
+
+func TestService_Export_idempotency(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestService()
+	account, _, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := s.PayIdempotent("key;1", account.ID, 100, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.PayIdempotent("key;2", account.ID, account.Balance+1, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Fatal(err)
+	}
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatalf("Export(): error = %v", err)
+	}
+
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatalf("Import(): error = %v", err)
+	}
+	got, err := imported.PayIdempotent("key;1", account.ID, 100, "auto")
+	if err != nil || got.ID != payment.ID {
+		t.Errorf("PayIdempotent() after import: payment = %v, error = %v", got, err)
+	}
+	_, err = imported.PayIdempotent("key;2", account.ID, account.Balance+1, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Errorf("PayIdempotent() after import: must return ErrNotEnoughBalance, returned = %v", err)
+	}
+}
+
+func TestService_Import_idempotencyErrors(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestService()
+	account, _, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetLimits(account.ID, types.Limits{Daily: 1_500_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, limitErr := s.PayIdempotent("limit", account.ID, 1_000_00, "auto")
+	if !errors.Is(limitErr, ErrLimitExceeded) {
+		t.Fatal(limitErr)
+	}
+	err = s.SetTierLimits(types.KYCTierAnonymous, types.TierLimits{MaxBalance: 1_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tierErr := s.DepositIdempotent("tier", account.ID, 100)
+	if !errors.Is(tierErr, ErrTierLimitExceeded) {
+		t.Fatal(tierErr)
+	}
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatalf("Export(): error = %v", err)
+	}
+
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatalf("Import(): error = %v", err)
+	}
+	_, err = imported.PayIdempotent("limit", account.ID, 1_000_00, "auto")
+	var restored *LimitError
+	if !errors.As(err, &restored) || !reflect.DeepEqual(restored, limitErr) {
+		t.Errorf("PayIdempotent() after import: must return %v, returned = %#v", limitErr, err)
+	}
+	err = imported.DepositIdempotent("tier", account.ID, 100)
+	if !errors.Is(err, ErrTierLimitExceeded) || err.Error() != tierErr.Error() {
+		t.Errorf("DepositIdempotent() after import: must return %v, returned = %v", tierErr, err)
+	}
+}
+
+
+func Test_restoreError(t *testing.T) {
+	codes := make(map[string]bool, len(errorCodes))
+	for known, code := range errorCodes {
+		if code == "" || codes[code] || strings.Contains(code, ":") {
+			t.Errorf("errorCodes: code %q of %v must be unique and not empty", code, known)
+		}
+		codes[code] = true
+		if errorCode(known) != code {
+			t.Errorf("errorCode(%v) = %q, must be %q", known, errorCode(known), code)
+		}
+		if restoreError(known.Error(), code) != known {
+			t.Errorf("restoreError(): code %q must restore %v", code, known)
+		}
+	}
+
+	wrapped := fmt.Errorf("deposit: %w", ErrTierLimitExceeded)
+	restored := restoreError(wrapped.Error(), errorCode(wrapped))
+	if !errors.Is(restored, ErrTierLimitExceeded) || restored.Error() != wrapped.Error() {
+		t.Errorf("restoreError(): must restore %v, returned = %v", wrapped, restored)
+	}
+	//в старых дампах кодом служил текст ошибки или кода не было вовсе
+	if restoreError("not enough balance", "not enough balance") != ErrNotEnoughBalance {
+		t.Errorf("restoreError(): must restore ErrNotEnoughBalance by the legacy code")
+	}
+	if restoreError("account is frozen", "") != ErrAccountFrozen {
+		t.Errorf("restoreError(): must restore ErrAccountFrozen by text")
+	}
+	restored = restoreError("storage is broken", "")
+	if restored == nil || restored.Error() != "storage is broken" {
+		t.Errorf("restoreError(): must keep an unknown error text, returned = %v", restored)
+	}
+}
@@ -0,0 +1,416 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidProfile = errors.New("invalid profile")
+var ErrInvalidTier = errors.New("invalid kyc tier")
+var ErrProfileIncomplete = errors.New("profile is not complete for the tier")
+var ErrUpgradeNotFound = errors.New("upgrade request not found")
+var ErrUpgradePending = errors.New("upgrade request already pending")
+var ErrUpgradeNotPending = errors.New("upgrade request already decided")
+var ErrTierLimitExceeded = errors.New("kyc tier limit exceeded")
+
+//tierRanks упорядочивает уровни идентификации
+var tierRanks = map[types.KYCTier]int{
+	types.KYCTierAnonymous: 0,
+	types.KYCTierBasic:     1,
+	types.KYCTierFull:      2,
+}
+
+//monthlyTotal - сумма пополнений счёта за месяц, начинающийся в month
+type monthlyTotal struct {
+	month  time.Time
+	amount types.Money
+}
+
+//SetTierLimits задаёт ограничения уровня идентификации. Пока ограничения не заданы,
+//уровень ничего не ограничивает, поэтому проверки включаются только по желанию
+func (s *Service) SetTierLimits(tier types.KYCTier, limits types.TierLimits) error {
+	_, ok := tierRanks[tier]
+	if !ok {
+		return ErrInvalidTier
+	}
+	if limits.MaxBalance < 0 || limits.MonthlyTurnover < 0 {
+		return ErrInvalidLimits
+	}
+	if s.tierLimits == nil {
+		s.tierLimits = make(map[types.KYCTier]types.TierLimits)
+	}
+	s.tierLimits[tier] = limits
+	return nil
+}
+
+//SetProfile сохраняет анкету клиента. Имя и дата рождения обязательны,
+//документ можно добавить позже, перед заявкой на уровень FULL
+func (s *Service) SetProfile(accountID int64, profile types.Profile) (types.Profile, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return types.Profile{}, err
+	}
+	err = checkOpen(account)
+	if err != nil {
+		return types.Profile{}, err
+	}
+	profile.Name = strings.TrimSpace(profile.Name)
+	profile.Document = strings.TrimSpace(profile.Document)
+	if profile.Name == "" || profile.BirthDate.IsZero() || profile.BirthDate.After(s.now()) {
+		return types.Profile{}, ErrInvalidProfile
+	}
+
+	if s.profiles == nil {
+		s.profiles = make(map[int64]types.Profile)
+	}
+	s.profiles[accountID] = profile
+	return profile, nil
+}
+
+//Profile возвращает анкету клиента; у счёта без анкеты она пустая
+func (s *Service) Profile(accountID int64) (types.Profile, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return types.Profile{}, err
+	}
+	return s.profiles[accountID], nil
+}
+
+//RequestUpgrade создаёт заявку на повышение уровня; анкета должна содержать
+//всё, что требует уровень. У счёта может быть только одна заявка на рассмотрении
+func (s *Service) RequestUpgrade(accountID int64, tier types.KYCTier) (*types.UpgradeRequest, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkOpen(account)
+	if err != nil {
+		return nil, err
+	}
+	rank, ok := tierRanks[tier]
+	if !ok || rank <= tierRanks[accountTier(account)] {
+		return nil, ErrInvalidTier
+	}
+	profile := s.profiles[accountID]
+	if profile.Name == "" || (tier == types.KYCTierFull && profile.Document == "") {
+		return nil, ErrProfileIncomplete
+	}
+	for _, request := range s.upgrades {
+		if request.AccountID == accountID && request.Status == types.UpgradeStatusPending {
+			return nil, ErrUpgradePending
+		}
+	}
+
+	request := &types.UpgradeRequest{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Tier:      tier,
+		Status:    types.UpgradeStatusPending,
+		CreatedAt: s.now(),
+	}
+	s.upgrades = append(s.upgrades, request)
+	return request, nil
+}
+
+//ApproveUpgrade одобряет заявку и повышает уровень счёта
+func (s *Service) ApproveUpgrade(requestID string) (*types.UpgradeRequest, error) {
+	request, err := s.pendingUpgrade(requestID)
+	if err != nil {
+		return nil, err
+	}
+	account, err := s.FindAccountByID(request.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	account.Tier = request.Tier
+	request.Status = types.UpgradeStatusApproved
+	return request, nil
+}
+
+//RejectUpgrade отклоняет заявку с указанием причины; уровень счёта не меняется
+func (s *Service) RejectUpgrade(requestID string, reason string) (*types.UpgradeRequest, error) {
+	request, err := s.pendingUpgrade(requestID)
+	if err != nil {
+		return nil, err
+	}
+	request.Status = types.UpgradeStatusRejected
+	request.Reason = strings.TrimSpace(reason)
+	return request, nil
+}
+
+//UpgradeRequests возвращает заявки счёта, от ранних к поздним
+func (s *Service) UpgradeRequests(accountID int64) ([]*types.UpgradeRequest, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	requests := make([]*types.UpgradeRequest, 0)
+	for _, request := range s.upgrades {
+		if request.AccountID == accountID {
+			requests = append(requests, request)
+		}
+	}
+	return requests, nil
+}
+
+func (s *Service) pendingUpgrade(requestID string) (*types.UpgradeRequest, error) {
+	for _, request := range s.upgrades {
+		if request.ID != requestID {
+			continue
+		}
+		if request.Status != types.UpgradeStatusPending {
+			return nil, ErrUpgradeNotPending
+		}
+		return request, nil
+	}
+	return nil, ErrUpgradeNotFound
+}
+
+//accountTier возвращает уровень счёта; счета из старых дампов анонимные
+func accountTier(account *types.Account) types.KYCTier {
+	if account.Tier == "" {
+		return types.KYCTierAnonymous
+	}
+	return account.Tier
+}
+
+//checkTier проверяет, что пополнение или платёж на сумму amount укладывается
+//в месячный оборот уровня счёта; остаток проверяет credit при зачислении
+func (s *Service) checkTier(account *types.Account, amount types.Money) error {
+	tier := accountTier(account)
+	limits, ok := s.tierLimits[tier]
+	if !ok {
+		return nil
+	}
+
+	if limits.MonthlyTurnover > 0 && s.turnover(account.ID)+amount > limits.MonthlyTurnover {
+		return fmt.Errorf("%w: %s monthly turnover limit %d", ErrTierLimitExceeded, tier, limits.MonthlyTurnover)
+	}
+	return nil
+}
+
+//credit зачисляет новые деньги на основной баланс счёта: пополнение, кэшбэк, остаток
+//закрытого счёта, обмен или перенос из конверта. Счёт должен быть активен, а остаток -
+//укладываться в ограничения уровня и в Money. Возвраты и отмены платежей лишь
+//возвращают списанное и зачисляются через creditPayment
+func (s *Service) credit(account *types.Account, amount types.Money) error {
+	err := checkActive(account)
+	if err != nil {
+		return err
+	}
+	err = s.checkBalanceLimit(account, amount)
+	if err != nil {
+		return err
+	}
+	return addMoney(&account.Balance, amount)
+}
+
+//checkBalanceLimit проверяет, что зачисление amount на счёт не превысит
+//допустимый для его уровня остаток вместе с конвертами
+func (s *Service) checkBalanceLimit(account *types.Account, amount types.Money) error {
+	tier := accountTier(account)
+	limits, ok := s.tierLimits[tier]
+	if !ok || limits.MaxBalance <= 0 {
+		return nil
+	}
+	balance := account.Balance
+	for _, envelope := range s.envelopes {
+		if envelope.AccountID == account.ID {
+			balance += envelope.Balance
+		}
+	}
+	if balance+amount > limits.MaxBalance {
+		return fmt.Errorf("%w: %s balance limit %d", ErrTierLimitExceeded, tier, limits.MaxBalance)
+	}
+	return nil
+}
+
+//turnover возвращает оборот счёта с начала месяца: пополнения и платежи
+func (s *Service) turnover(accountID int64) types.Money {
+	monthStart := startOfMonth(s.now())
+	deposited := s.deposited[accountID]
+	if deposited.month.Before(monthStart) {
+		deposited.amount = 0
+	}
+	return deposited.amount + s.spent(accountID, monthStart, "")
+}
+
+//recordDeposit учитывает пополнение в обороте текущего месяца
+func (s *Service) recordDeposit(accountID int64, amount types.Money) {
+	monthStart := startOfMonth(s.now())
+	if s.deposited == nil {
+		s.deposited = make(map[int64]monthlyTotal)
+	}
+	deposited := s.deposited[accountID]
+	if !deposited.month.Equal(monthStart) {
+		deposited = monthlyTotal{month: monthStart}
+	}
+	deposited.amount += amount
+	s.deposited[accountID] = deposited
+}
+
+func (s *Service) exportProfiles(dir string) error {
+	records := make([][]string, 0, len(s.profiles))
+	for _, account := range s.accounts {
+		profile, ok := s.profiles[account.ID]
+		if !ok {
+			continue
+		}
+		records = append(records, []string{
+			strconv.FormatInt(account.ID, 10),
+			escape(profile.Name),
+			formatTime(profile.BirthDate),
+			escape(profile.Document),
+		})
+	}
+	return writeDump(dir+"/profiles.dump", records)
+}
+
+func (s *Service) importProfiles(dir string) error {
+	records, err := readDump(dir + "/profiles.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 4 {
+			err = fmt.Errorf("profiles.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		accountID, err := strconv.ParseInt(splits[0], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		name, err := unescape(splits[1])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		birthDate, err := parseTime(splits[2])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		document, err := unescape(splits[3])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		if s.profiles == nil {
+			s.profiles = make(map[int64]types.Profile)
+		}
+		s.profiles[accountID] = types.Profile{Name: name, BirthDate: birthDate, Document: document}
+	}
+	return nil
+}
+
+func (s *Service) exportUpgrades(dir string) error {
+	records := make([][]string, 0, len(s.upgrades))
+	for _, request := range s.upgrades {
+		records = append(records, []string{
+			request.ID,
+			strconv.FormatInt(request.AccountID, 10),
+			string(request.Tier),
+			string(request.Status),
+			escape(request.Reason),
+			formatTime(request.CreatedAt),
+		})
+	}
+	return writeDump(dir+"/upgrades.dump", records)
+}
+
+func (s *Service) importUpgrades(dir string) error {
+	records, err := readDump(dir + "/upgrades.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 6 {
+			err = fmt.Errorf("upgrades.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		accountID, err := strconv.ParseInt(splits[1], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		reason, err := unescape(splits[4])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		createdAt, err := parseTime(splits[5])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		s.upgrades = append(s.upgrades, &types.UpgradeRequest{
+			ID:        splits[0],
+			AccountID: accountID,
+			Tier:      types.KYCTier(splits[2]),
+			Status:    types.UpgradeStatus(splits[3]),
+			Reason:    reason,
+			CreatedAt: createdAt,
+		})
+	}
+	return nil
+}
+
+func (s *Service) exportDeposited(dir string) error {
+	records := make([][]string, 0, len(s.deposited))
+	for _, account := range s.accounts {
+		deposited, ok := s.deposited[account.ID]
+		if !ok {
+			continue
+		}
+		records = append(records, []string{
+			strconv.FormatInt(account.ID, 10),
+			formatTime(deposited.month),
+			strconv.FormatInt(int64(deposited.amount), 10),
+		})
+	}
+	return writeDump(dir+"/deposits.dump", records)
+}
+
+func (s *Service) importDeposited(dir string) error {
+	records, err := readDump(dir + "/deposits.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 3 {
+			err = fmt.Errorf("deposits.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		accountID, err := strconv.ParseInt(splits[0], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		month, err := parseTime(splits[1])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		amount, err := strconv.ParseInt(splits[2], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		if s.deposited == nil {
+			s.deposited = make(map[int64]monthlyTotal)
+		}
+		s.deposited[accountID] = monthlyTotal{month: month, amount: types.Money(amount)}
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestService_SetProfile(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	birthDate := time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC)
+	profile, err := s.SetProfile(account.ID, types.Profile{Name: " Behzod ", BirthDate: birthDate})
+	if err != nil {
+		t.Fatalf("SetProfile(): error = %v", err)
+	}
+	if profile.Name != "Behzod" {
+		t.Errorf("SetProfile(): name must be trimmed, profile = %v", profile)
+	}
+	for _, invalid := range []types.Profile{
+		{Name: " ", BirthDate: birthDate},
+		{Name: "Behzod"},
+		{Name: "Behzod", BirthDate: now.AddDate(0, 0, 1)},
+	} {
+		_, err = s.SetProfile(account.ID, invalid)
+		if err != ErrInvalidProfile {
+			t.Errorf("SetProfile(%v): must return ErrInvalidProfile, returned = %v", invalid, err)
+		}
+	}
+	got, err := s.Profile(account.ID)
+	if err != nil || got != profile {
+		t.Errorf("Profile(): profile = %v, error = %v", got, err)
+	}
+}
+
+func TestService_RequestUpgrade(t *testing.T) {
+	s := newTestService()
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Tier != types.KYCTierAnonymous {
+		t.Errorf("RegisterAccount(): tier = %v", account.Tier)
+	}
+
+	_, err = s.RequestUpgrade(account.ID, types.KYCTierBasic)
+	if err != ErrProfileIncomplete {
+		t.Errorf("RequestUpgrade(): must return ErrProfileIncomplete, returned = %v", err)
+	}
+	_, err = s.SetProfile(account.ID, types.Profile{Name: "Behzod", BirthDate: time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.RequestUpgrade(account.ID, types.KYCTierFull)
+	if err != ErrProfileIncomplete {
+		t.Errorf("RequestUpgrade(): FULL without document must return ErrProfileIncomplete, returned = %v", err)
+	}
+	_, err = s.RequestUpgrade(account.ID, types.KYCTierAnonymous)
+	if err != ErrInvalidTier {
+		t.Errorf("RequestUpgrade(): must return ErrInvalidTier, returned = %v", err)
+	}
+
+	request, err := s.RequestUpgrade(account.ID, types.KYCTierBasic)
+	if err != nil {
+		t.Fatalf("RequestUpgrade(): error = %v", err)
+	}
+	_, err = s.RequestUpgrade(account.ID, types.KYCTierBasic)
+	if err != ErrUpgradePending {
+		t.Errorf("RequestUpgrade(): must return ErrUpgradePending, returned = %v", err)
+	}
+	_, err = s.RejectUpgrade(request.ID, " blurry photo ")
+	if err != nil {
+		t.Fatalf("RejectUpgrade(): error = %v", err)
+	}
+	if request.Status != types.UpgradeStatusRejected || request.Reason != "blurry photo" || account.Tier != types.KYCTierAnonymous {
+		t.Errorf("RejectUpgrade(): request = %v, tier = %v", request, account.Tier)
+	}
+	_, err = s.ApproveUpgrade(request.ID)
+	if err != ErrUpgradeNotPending {
+		t.Errorf("ApproveUpgrade(): must return ErrUpgradeNotPending, returned = %v", err)
+	}
+
+	request, err = s.RequestUpgrade(account.ID, types.KYCTierBasic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ApproveUpgrade(request.ID)
+	if err != nil {
+		t.Fatalf("ApproveUpgrade(): error = %v", err)
+	}
+	if account.Tier != types.KYCTierBasic {
+		t.Errorf("ApproveUpgrade(): tier = %v", account.Tier)
+	}
+	_, err = s.ApproveUpgrade("unknown")
+	if err != ErrUpgradeNotFound {
+		t.Errorf("ApproveUpgrade(): must return ErrUpgradeNotFound, returned = %v", err)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := imported.FindAccountByID(account.ID)
+	if err != nil || got.Tier != types.KYCTierBasic {
+		t.Errorf("Import(): account = %v, error = %v", got, err)
+	}
+	profile, _ := imported.Profile(account.ID)
+	requests, _ := imported.UpgradeRequests(account.ID)
+	if profile.Name != "Behzod" || len(requests) != 2 || requests[0].Reason != "blurry photo" {
+		t.Errorf("Import(): profile = %v, requests = %v", profile, requests)
+	}
+}
+
+func TestService_checkTier(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//без заданных ограничений уровень ничего не ограничивает
+	err = s.Deposit(account.ID, 1_000_000_00)
+	if err != nil {
+		t.Fatalf("Deposit(): error = %v", err)
+	}
+	_, err = s.Pay(account.ID, 999_000_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.SetTierLimits(types.KYCTierAnonymous, types.TierLimits{MaxBalance: 5_000_00, MonthlyTurnover: 2_001_000_00})
+	if err != nil {
+		t.Fatalf("SetTierLimits(): error = %v", err)
+	}
+	err = s.Deposit(account.ID, 4_000_01)
+	if !errors.Is(err, ErrTierLimitExceeded) {
+		t.Errorf("Deposit(): balance limit must return ErrTierLimitExceeded, returned = %v", err)
+	}
+	err = s.Deposit(account.ID, 1_500_00)
+	if err != nil {
+		t.Fatalf("Deposit(): error = %v", err)
+	}
+	//оборот за месяц: 1 001 500 пополнений и 999 000 платежей
+	_, err = s.Pay(account.ID, 1_500_00, "auto")
+	if !errors.Is(err, ErrTierLimitExceeded) {
+		t.Errorf("Pay(): turnover limit must return ErrTierLimitExceeded, returned = %v", err)
+	}
+
+	//в новом месяце оборот считается заново
+	now = now.AddDate(0, 1, 0)
+	_, err = s.Pay(account.ID, 1_500_00, "auto")
+	if err != nil {
+		t.Errorf("Pay(): error in the next month = %v", err)
+	}
+
+	//комиссия входит в оборот вместе с платежом
+	err = s.SetFeeRule(types.FeeRule{Category: "auto", Fixed: 1_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetTierLimits(types.KYCTierAnonymous, types.TierLimits{MaxBalance: 5_000_00, MonthlyTurnover: 1_500_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Pay(account.ID, 1_500_00, "auto")
+	if !errors.Is(err, ErrTierLimitExceeded) {
+		t.Errorf("Pay(): fee must count toward the turnover, returned = %v", err)
+	}
+
+	err = s.SetTierLimits("UNKNOWN", types.TierLimits{})
+	if err != ErrInvalidTier {
+		t.Errorf("SetTierLimits(): must return ErrInvalidTier, returned = %v", err)
+	}
+	err = s.SetTierLimits(types.KYCTierBasic, types.TierLimits{MaxBalance: -1})
+	if err != ErrInvalidLimits {
+		t.Errorf("SetTierLimits(): must return ErrInvalidLimits, returned = %v", err)
+	}
+}
+
+func TestService_checkBalanceLimit(t *testing.T) {
+	s := newTestService()
+	s.SetClock(func() time.Time { return time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC) })
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payout, err := s.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usd, err := s.RegisterAccountInCurrency("+992000000002", types.USD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 3_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(payout.ID, 3_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(usd.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates := NewStaticRates()
+	rates.Set(types.USD, types.TJS, 30_000_000)
+	s.SetRateProvider(rates)
+	err = s.SetTierLimits(types.KYCTierAnonymous, types.TierLimits{MaxBalance: 5_000_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Close(account.ID, payout.ID)
+	if !errors.Is(err, ErrTierLimitExceeded) || account.Status == types.AccountStatusClosed || payout.Balance != 3_000_00 {
+		t.Errorf("Close(): payout must respect the balance limit, returned = %v, balance = %v", err, payout.Balance)
+	}
+	_, err = s.Convert(usd.ID, payout.ID, 100_00)
+	if !errors.Is(err, ErrTierLimitExceeded) || usd.Balance != 100_00 || payout.Balance != 3_000_00 {
+		t.Errorf("Convert(): credit must respect the balance limit, returned = %v, balances = %v, %v", err, usd.Balance, payout.Balance)
+	}
+	_, err = s.Convert(usd.ID, payout.ID, 50_00)
+	if err != nil {
+		t.Errorf("Convert(): error = %v", err)
+	}
+
+	//перенос между конвертом и счётом не меняет остаток и проходит у самого предела
+	envelope, err := s.CreateEnvelope(payout.ID, "rent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.MoveToEnvelope(envelope.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.MoveFromEnvelope(envelope.ID, 500_00)
+	if err != nil {
+		t.Errorf("MoveFromEnvelope(): error = %v", err)
+	}
+	err = s.RemoveEnvelope(envelope.ID)
+	if err != nil || payout.Balance != 4_500_00 {
+		t.Errorf("RemoveEnvelope(): error = %v, balance = %v", err, payout.Balance)
+	}
+
+	//кэшбэк тоже зачисляется только в пределах остатка и на активный счёт
+	err = s.SetCashbackRule(types.CashbackRule{Category: "cafe", Percent: 10_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := s.Pay(payout.ID, 1_000_00, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetTierLimits(types.KYCTierAnonymous, types.TierLimits{MaxBalance: 4_000_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.CompletePayment(payment.ID)
+	if !errors.Is(err, ErrTierLimitExceeded) || payment.Status != types.PaymentStatusInProgress || payout.Balance != 3_500_00 {
+		t.Errorf("CompletePayment(): cashback must respect the balance limit, returned = %v, status = %v", err, payment.Status)
+	}
+	err = s.SetTierLimits(types.KYCTierAnonymous, types.TierLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Freeze(payout.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.CompletePayment(payment.ID)
+	if err != ErrAccountFrozen || payment.Status != types.PaymentStatusInProgress {
+		t.Errorf("CompletePayment(): must return ErrAccountFrozen, returned = %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+var ErrLimitExceeded = errors.New("limit exceeded")
+var ErrInvalidLimits = errors.New("invalid limits")
+
+//LimitError сообщает, какой лимит не позволил выполнить платёж и сколько ещё можно потратить.
+//errors.Is(err, ErrLimitExceeded) истинно для любой LimitError
+type LimitError struct {
+	Kind      types.LimitKind
+	Category  types.PaymentCategory
+	Currency  types.Currency
+	Limit     types.Money
+	Remaining types.Money
+}
+
+func (e *LimitError) Error() string {
+	kind := string(e.Kind)
+	if e.Kind == types.LimitCategory {
+		kind += " " + string(e.Category)
+	}
+	return fmt.Sprintf("%v: %s limit %d, remaining %d", ErrLimitExceeded, kind, e.Limit, e.Remaining)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+//SetLimits задаёт лимиты расходов счёта; нулевые Limits снимают все ограничения
+func (s *Service) SetLimits(accountID int64, limits types.Limits) error {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if limits.PerTransaction < 0 || limits.Daily < 0 || limits.Monthly < 0 {
+		return ErrInvalidLimits
+	}
+	categories := make(map[types.PaymentCategory]types.Money)
+	for category, limit := range limits.Categories {
+		if limit < 0 {
+			return ErrInvalidLimits
+		}
+		if limit > 0 {
+			category, err = s.resolveCategory(category)
+			if err != nil {
+				return err
+			}
+			categories[category] = limit
+		}
+	}
+	limits.Categories = categories
+
+	if s.limits == nil {
+		s.limits = make(map[int64]types.Limits)
+	}
+	s.limits[accountID] = limits
+	return nil
+}
+
+//Limits возвращает лимиты расходов счёта
+func (s *Service) Limits(accountID int64) (types.Limits, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return types.Limits{}, err
+	}
+	limits := s.limits[accountID]
+	categories := make(map[types.PaymentCategory]types.Money)
+	for category, limit := range limits.Categories {
+		categories[category] = limit
+	}
+	limits.Categories = categories
+	return limits, nil
+}
+
+//checkLimits проверяет, что платёж на сумму amount укладывается в лимиты счёта
+func (s *Service) checkLimits(account *types.Account, amount types.Money, category types.PaymentCategory) error {
+	accountID := account.ID
+	limits, ok := s.limits[accountID]
+	if !ok {
+		return nil
+	}
+
+	if limits.PerTransaction > 0 && amount > limits.PerTransaction {
+		return &LimitError{Kind: types.LimitPerTransaction, Currency: account.Currency, Limit: limits.PerTransaction, Remaining: limits.PerTransaction}
+	}
+
+	now := s.now()
+	year, month, day := now.Date()
+	dayStart := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	monthStart := startOfMonth(now)
+
+	type limitCheck struct {
+		kind     types.LimitKind
+		limit    types.Money
+		since    time.Time
+		category types.PaymentCategory
+	}
+	checks := make([]limitCheck, 0)
+	//лимит родительской категории распространяется и на вложенные
+	for _, code := range s.categoryPath(category) {
+		for limitCategory, limit := range limits.Categories {
+			if s.sameCategory(limitCategory, code) {
+				checks = append(checks, limitCheck{types.LimitCategory, limit, monthStart, code})
+			}
+		}
+	}
+	checks = append(checks,
+		limitCheck{types.LimitDaily, limits.Daily, dayStart, ""},
+		limitCheck{types.LimitMonthly, limits.Monthly, monthStart, ""},
+	)
+	for _, check := range checks {
+		if check.limit == 0 {
+			continue
+		}
+		remaining := check.limit - s.spent(accountID, check.since, check.category)
+		if remaining < 0 {
+			remaining = 0
+		}
+		if amount > remaining {
+			return &LimitError{Kind: check.kind, Category: check.category, Currency: account.Currency, Limit: check.limit, Remaining: remaining}
+		}
+	}
+	return nil
+}
+
+func startOfMonth(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
+
+//spent возвращает списания со счёта для лимитов и оборота: платежи вместе
+//с комиссией начиная с момента since, а для пустой категории - ещё и обмены
+func (s *Service) spent(accountID int64, since time.Time, category types.PaymentCategory) types.Money {
+	sum := s.paid(accountID, since, category, true)
+	if category != "" {
+		return sum
+	}
+	for _, conversion := range s.conversions {
+		if conversion.FromAccountID == accountID && !conversion.CreatedAt.Before(since) {
+			sum += conversion.Amount
+		}
+	}
+	return sum
+}
+
+//paid возвращает сумму платежей счёта начиная с момента since без учёта отменённых
+//платежей и возвратов; withFee добавляет к ней списанную комиссию. Платежи вложенных
+//категорий учитываются в родительской; пустая категория означает все категории
+func (s *Service) paid(accountID int64, since time.Time, category types.PaymentCategory, withFee bool) types.Money {
+	sum := types.Money(0)
+	for _, payment := range s.payments {
+		if payment.AccountID != accountID || payment.Status == types.PaymentStatusFail {
+			continue
+		}
+		if category != "" && !s.inCategory(payment.Category, category) {
+			continue
+		}
+		if payment.CreatedAt.Before(since) {
+			continue
+		}
+		sum += payment.Amount - payment.Refunded
+		if withFee {
+			sum += payment.Fee - payment.FeeRefunded
+		}
+	}
+	return sum
+}
+
+func (s *Service) exportLimits(dir string) error {
+	records := make([][]string, 0, len(s.limits))
+	for _, account := range s.accounts {
+		limits, ok := s.limits[account.ID]
+		if !ok {
+			continue
+		}
+		categories := make([]string, 0, len(limits.Categories))
+		for category, limit := range limits.Categories {
+			categories = append(categories, escape(string(category))+"="+strconv.FormatInt(int64(limit), 10))
+		}
+		records = append(records, []string{
+			strconv.FormatInt(account.ID, 10),
+			strconv.FormatInt(int64(limits.PerTransaction), 10),
+			strconv.FormatInt(int64(limits.Daily), 10),
+			strconv.FormatInt(int64(limits.Monthly), 10),
+			strings.Join(categories, "&"),
+		})
+	}
+	return writeDump(dir+"/limits.dump", records)
+}
+
+func (s *Service) importLimits(dir string) error {
+	records, err := readDump(dir + "/limits.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 5 {
+			err = fmt.Errorf("limits.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		values := make([]int64, 4)
+		for i := range values {
+			values[i], err = strconv.ParseInt(splits[i], 10, 64)
+			if err != nil {
+				log.Print(err)
+				return err
+			}
+		}
+		categories := make(map[types.PaymentCategory]types.Money)
+		if splits[4] != "" {
+			for _, part := range strings.Split(splits[4], "&") {
+				pair := strings.Split(part, "=")
+				if len(pair) != 2 {
+					err = fmt.Errorf("limits.dump: wrong record %v", splits)
+					log.Print(err)
+					return err
+				}
+				category, err := unescape(pair[0])
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+				limit, err := strconv.ParseInt(pair[1], 10, 64)
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+				categories[types.PaymentCategory(category)] = types.Money(limit)
+			}
+		}
+		if s.limits == nil {
+			s.limits = make(map[int64]types.Limits)
+		}
+		s.limits[values[0]] = types.Limits{
+			PerTransaction: types.Money(values[1]),
+			Daily:          types.Money(values[2]),
+			Monthly:        types.Money(values[3]),
+			Categories:     categories,
+		}
+	}
+	return nil
+}
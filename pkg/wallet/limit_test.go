@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestService_Pay_limits(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2024, time.March, 31, 12, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 10_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetLimits(account.ID, types.Limits{
+		PerTransaction: 500_00,
+		Daily:          800_00,
+		Monthly:        1_000_00,
+		Categories:     map[types.PaymentCategory]types.Money{"cafe": 300_00},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var limitErr *LimitError
+	_, err = s.Pay(account.ID, 500_01, "auto")
+	if !errors.As(err, &limitErr) || limitErr.Kind != types.LimitPerTransaction {
+		t.Errorf("Pay(): must exceed per-transaction limit, error = %v", err)
+	}
+
+	_, err = s.Pay(account.ID, 200_00, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Pay(account.ID, 150_00, "cafe")
+	if !errors.As(err, &limitErr) || limitErr.Kind != types.LimitCategory || limitErr.Remaining != 100_00 {
+		t.Errorf("Pay(): must exceed cafe limit, error = %v", err)
+	}
+
+	payment, err := s.Pay(account.ID, 500_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Repeat(payment.ID)
+	if !errors.Is(err, ErrLimitExceeded) || !errors.As(err, &limitErr) || limitErr.Kind != types.LimitDaily || limitErr.Remaining != 100_00 {
+		t.Errorf("Repeat(): must exceed daily limit, error = %v", err)
+	}
+
+	err = s.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(24 * time.Hour)
+	_, err = s.Pay(account.ID, 500_00, "auto")
+	if err != nil {
+		t.Errorf("Pay(): rejected payments and previous month must not count, error = %v", err)
+	}
+	_, err = s.Pay(account.ID, 500_00, "auto")
+	if !errors.As(err, &limitErr) || limitErr.Kind != types.LimitDaily {
+		t.Errorf("Pay(): must exceed daily limit, error = %v", err)
+	}
+	if account.Balance != 10_000_00-200_00-500_00 {
+		t.Errorf("Pay(): failed payments must not change balance, balance = %v", account.Balance)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits, err := imported.Limits(account.ID)
+	if err != nil || limits.Monthly != 1_000_00 || limits.Categories["cafe"] != 300_00 {
+		t.Errorf("Import(): limits = %v, error = %v", limits, err)
+	}
+	imported.SetClock(func() time.Time { return now })
+	_, err = imported.Pay(account.ID, 400_00, "auto")
+	if !errors.As(err, &limitErr) || limitErr.Kind != types.LimitDaily {
+		t.Errorf("Import(): payment times must be restored, error = %v", err)
+	}
+}
+
+func TestService_SetLimits_invalid(t *testing.T) {
+	s := newTestService()
+	account, _, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetLimits(account.ID, types.Limits{Daily: -1})
+	if err != ErrInvalidLimits {
+		t.Errorf("SetLimits(): must return ErrInvalidLimits, returned = %v", err)
+	}
+	err = s.SetLimits(404, types.Limits{})
+	if err != ErrAccountNotFound {
+		t.Errorf("SetLimits(): must return ErrAccountNotFound, returned = %v", err)
+	}
+}
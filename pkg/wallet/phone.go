@@ -0,0 +1,250 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+var ErrUnsupportedCountry = errors.New("unsupported phone country code")
+
+//phoneRule описывает номера страны: код страны и длину национального номера
+type phoneRule struct {
+	country string
+	code    string
+	length  int
+}
+
+//phoneRules - поддерживаемые страны; первая используется для номеров без кода страны
+var phoneRules = []phoneRule{
+	{country: "TJ", code: "992", length: 9},
+	{country: "UZ", code: "998", length: 9},
+	{country: "KG", code: "996", length: 9},
+	{country: "KZ", code: "7", length: 10},
+}
+
+//NormalizePhone приводит номер к формату E.164: "+992000000001".
+//Пробелы, дефисы, точки и скобки отбрасываются; номер может начинаться с "+",
+//"00" или сразу с кода страны, а номер без кода считается таджикским
+func NormalizePhone(phone types.Phone) (types.Phone, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')', ' ':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(string(phone)))
+
+	local := phoneRules[0]
+	international := false
+	switch {
+	case strings.HasPrefix(digits, "+"):
+		digits, international = digits[1:], true
+	case len(digits) == local.length:
+		//национальный номер может начинаться с нулей, поэтому проверяется раньше "00"
+	case strings.HasPrefix(digits, "00"):
+		digits, international = digits[2:], true
+	}
+	if digits == "" || !onlyDigits(digits) {
+		return "", ErrInvalidPhone
+	}
+
+	if !international {
+		if len(digits) == local.length {
+			return types.Phone("+" + local.code + digits), nil
+		}
+		if len(digits) != len(local.code)+local.length || !strings.HasPrefix(digits, local.code) {
+			return "", ErrInvalidPhone
+		}
+	}
+
+	for _, rule := range phoneRules {
+		if !strings.HasPrefix(digits, rule.code) {
+			continue
+		}
+		if len(digits) != len(rule.code)+rule.length {
+			return "", ErrInvalidPhone
+		}
+		return types.Phone("+" + digits), nil
+	}
+	return "", ErrUnsupportedCountry
+}
+
+//FindAccountByPhone возвращает первый открытый на номер счёт; номер может быть в любом
+//формате, который понимает NormalizePhone
+func (s *Service) FindAccountByPhone(phone types.Phone) (*types.Account, error) {
+	accounts, err := s.AccountsByPhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return nil, ErrAccountNotFound
+	}
+	return accounts[0], nil
+}
+
+//AccountsByPhone возвращает все счета номера, по одному на каждую валюту
+func (s *Service) AccountsByPhone(phone types.Phone) ([]*types.Account, error) {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]*types.Account, 0)
+	for _, account := range s.accounts {
+		if account.Phone == phone {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+//ChangePhone переводит счёт на новый номер. Все счета клиента в других валютах
+//переезжают вместе с ним, а прежний номер сохраняется в истории и освобождается
+func (s *Service) ChangePhone(accountID int64, phone types.Phone) (*types.Account, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkOpen(account)
+	if err != nil {
+		return nil, err
+	}
+	phone, err = NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	if phone == account.Phone {
+		return account, nil
+	}
+	for _, other := range s.accounts {
+		if other.Phone == phone {
+			return nil, ErrPhoneRegistered
+		}
+	}
+
+	previous := account.Phone
+	now := s.now()
+	for _, other := range s.accounts {
+		if other.Phone != previous {
+			continue
+		}
+		other.Phone = phone
+		s.phoneChanges = append(s.phoneChanges, &types.PhoneChange{
+			AccountID: other.ID,
+			Previous:  previous,
+			Phone:     phone,
+			ChangedAt: now,
+		})
+	}
+	return account, nil
+}
+
+//PhoneHistory возвращает смены номера счёта, от ранних к поздним
+func (s *Service) PhoneHistory(accountID int64) ([]*types.PhoneChange, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]*types.PhoneChange, 0)
+	for _, change := range s.phoneChanges {
+		if change.AccountID == accountID {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+//AccountsByPhoneHistory возвращает счета, которые когда-либо были открыты на номер,
+//включая текущих владельцев; нужен для разбирательств службы поддержки
+func (s *Service) AccountsByPhoneHistory(phone types.Phone) ([]*types.Account, error) {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]*types.Account, 0)
+	for _, account := range s.accounts {
+		if account.Phone == phone || s.hadPhone(account.ID, phone) {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+func (s *Service) hadPhone(accountID int64, phone types.Phone) bool {
+	for _, change := range s.phoneChanges {
+		if change.AccountID == accountID && change.Previous == phone {
+			return true
+		}
+	}
+	return false
+}
+
+//importedPhone приводит номер из дампа к E.164; номера, которые не удалось разобрать,
+//остаются как есть, чтобы старые дампы продолжали загружаться
+func importedPhone(raw string) types.Phone {
+	phone, err := NormalizePhone(types.Phone(raw))
+	if err != nil {
+		return types.Phone(raw)
+	}
+	return phone
+}
+
+//onlyDigits сообщает, состоит ли строка только из цифр ASCII; пустая строка подходит
+func onlyDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Service) exportPhoneChanges(dir string) error {
+	records := make([][]string, 0, len(s.phoneChanges))
+	for _, change := range s.phoneChanges {
+		records = append(records, []string{
+			strconv.FormatInt(change.AccountID, 10),
+			string(change.Previous),
+			string(change.Phone),
+			strconv.FormatInt(change.ChangedAt.Unix(), 10),
+		})
+	}
+	return writeDump(dir+"/phones.dump", records)
+}
+
+func (s *Service) importPhoneChanges(dir string) error {
+	records, err := readDump(dir + "/phones.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 4 {
+			err = fmt.Errorf("phones.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		accountID, err := strconv.ParseInt(splits[0], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		changedAt, err := strconv.ParseInt(splits[3], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		s.phoneChanges = append(s.phoneChanges, &types.PhoneChange{
+			AccountID: accountID,
+			Previous:  importedPhone(splits[1]),
+			Phone:     importedPhone(splits[2]),
+			ChangedAt: time.Unix(changedAt, 0),
+		})
+	}
+	return nil
+}
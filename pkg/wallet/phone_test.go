@@ -0,0 +1,152 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone types.Phone
+		want  types.Phone
+	}{
+		{"+992000000001", "+992000000001"},
+		{"992 000 00 00 01", "+992000000001"},
+		{"00992000000001", "+992000000001"},
+		{"(90) 979-66-00", "+992909796600"},
+		{"909796600", "+992909796600"},
+		{"+998 90 123 45 67", "+998901234567"},
+		{"+7 701 123 45 67", "+77011234567"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizePhone(tt.phone)
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizePhone(%q) = %q, %v; want %q", tt.phone, got, err, tt.want)
+		}
+	}
+
+	for _, phone := range []types.Phone{"", "+", "12345", "+99200000000", "+9920000000011", "90979660a", "7835628753"} {
+		_, err := NormalizePhone(phone)
+		if err != ErrInvalidPhone {
+			t.Errorf("NormalizePhone(%q): must return ErrInvalidPhone, returned = %v", phone, err)
+		}
+	}
+	_, err := NormalizePhone("+44 20 7946 0958")
+	if err != ErrUnsupportedCountry {
+		t.Errorf("NormalizePhone(): must return ErrUnsupportedCountry, returned = %v", err)
+	}
+}
+
+func TestService_FindAccountByPhone(t *testing.T) {
+	s := newTestService()
+	account, err := s.RegisterAccount("992 000 00 00 01")
+	if err != nil {
+		t.Fatalf("RegisterAccount(): error = %v", err)
+	}
+	if account.Phone != "+992000000001" {
+		t.Errorf("RegisterAccount(): phone must be normalized, phone = %q", account.Phone)
+	}
+	_, err = s.RegisterAccount("00992000000001")
+	if err != ErrPhoneRegistered {
+		t.Errorf("RegisterAccount(): must return ErrPhoneRegistered, returned = %v", err)
+	}
+	_, err = s.RegisterAccount("1234")
+	if err != ErrInvalidPhone {
+		t.Errorf("RegisterAccount(): must return ErrInvalidPhone, returned = %v", err)
+	}
+
+	found, err := s.FindAccountByPhone("000 00 00 01")
+	if err != nil || found != account {
+		t.Errorf("FindAccountByPhone(): account = %v, error = %v", found, err)
+	}
+	_, err = s.FindAccountByPhone("+992000000002")
+	if err != ErrAccountNotFound {
+		t.Errorf("FindAccountByPhone(): must return ErrAccountNotFound, returned = %v", err)
+	}
+	_, err = s.FindAccountByPhone("abc")
+	if err != ErrInvalidPhone {
+		t.Errorf("FindAccountByPhone(): must return ErrInvalidPhone, returned = %v", err)
+	}
+}
+
+func TestService_ChangePhone(t *testing.T) {
+	s := newTestService()
+	changedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return changedAt })
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dollars, err := s.RegisterAccountInCurrency("+992000000001", types.USD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.ChangePhone(account.ID, "000 00 00 02")
+	if err != ErrPhoneRegistered {
+		t.Errorf("ChangePhone(): must return ErrPhoneRegistered, returned = %v", err)
+	}
+	_, err = s.ChangePhone(account.ID, "123")
+	if err != ErrInvalidPhone {
+		t.Errorf("ChangePhone(): must return ErrInvalidPhone, returned = %v", err)
+	}
+
+	_, err = s.ChangePhone(account.ID, "000 00 00 03")
+	if err != nil {
+		t.Fatalf("ChangePhone(): error = %v", err)
+	}
+	if account.Phone != "+992000000003" || dollars.Phone != "+992000000003" {
+		t.Errorf("ChangePhone(): all accounts of the customer must move, phones = %v, %v", account.Phone, dollars.Phone)
+	}
+	history, err := s.PhoneHistory(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := types.PhoneChange{AccountID: account.ID, Previous: "+992000000001", Phone: "+992000000003", ChangedAt: changedAt}
+	if len(history) != 1 || *history[0] != want {
+		t.Errorf("PhoneHistory(): history = %v", history)
+	}
+
+	//прежний номер освобождается, но поиск по истории находит и старый счёт
+	newcomer, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatalf("RegisterAccount(): previous phone must be free, error = %v", err)
+	}
+	accounts, err := s.AccountsByPhoneHistory("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 3 || accounts[0] != account || accounts[1] != dollars || accounts[2] != newcomer {
+		t.Errorf("AccountsByPhoneHistory(): accounts = %v", accounts)
+	}
+
+	err = s.Close(other.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ChangePhone(other.ID, "+992000000004")
+	if err != ErrAccountClosed {
+		t.Errorf("ChangePhone(): must return ErrAccountClosed, returned = %v", err)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err = imported.PhoneHistory(dollars.ID)
+	if err != nil || len(history) != 1 || history[0].Previous != "+992000000001" || !history[0].ChangedAt.Equal(changedAt) {
+		t.Errorf("Import(): history = %v, error = %v", history, err)
+	}
+}
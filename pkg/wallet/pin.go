@@ -0,0 +1,325 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+var ErrInvalidPIN = errors.New("pin must be 4 to 6 digits")
+var ErrPINNotSet = errors.New("pin is not set")
+var ErrWrongPIN = errors.New("wrong pin")
+var ErrPINLocked = errors.New("pin is locked")
+
+//DefaultPINPolicy используется, пока политика не задана через SetPINPolicy
+var DefaultPINPolicy = types.PINPolicy{
+	MaxAttempts: 3,
+	Lockout:     5 * time.Minute,
+	MaxLockout:  24 * time.Hour,
+}
+
+//pinIterations - число итераций PBKDF2; делает подбор ПИН-кода по утёкшему хешу дорогим
+const pinIterations = 100_000
+
+const pinSaltSize = 16
+
+//pinRecord хранит хеш ПИН-кода счёта и состояние блокировки
+type pinRecord struct {
+	salt       []byte
+	hash       []byte
+	iterations int
+	//failures - неверные попытки подряд, lockouts - блокировки подряд
+	failures    int
+	lockouts    int
+	lockedUntil time.Time
+}
+
+//SetPINPolicy задаёт число попыток и длительность блокировки ПИН-кода
+func (s *Service) SetPINPolicy(policy types.PINPolicy) {
+	s.pinPolicy = policy
+}
+
+//SetPIN задаёт ПИН-код счёта. Если ПИН-код уже задан, его нужно подтвердить
+//в current; неверный current считается неудачной попыткой
+func (s *Service) SetPIN(accountID int64, current string, pin string) error {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	err = checkOpen(account)
+	if err != nil {
+		return err
+	}
+	if !validPIN(pin) {
+		return ErrInvalidPIN
+	}
+	if s.HasPIN(accountID) {
+		err = s.VerifyPIN(accountID, current)
+		if err != nil {
+			return err
+		}
+	}
+
+	salt := make([]byte, pinSaltSize)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return err
+	}
+	if s.pins == nil {
+		s.pins = make(map[int64]*pinRecord)
+	}
+	s.pins[accountID] = &pinRecord{
+		salt:       salt,
+		hash:       pbkdf2([]byte(pin), salt, pinIterations, sha256.Size),
+		iterations: pinIterations,
+	}
+	return nil
+}
+
+//HasPIN сообщает, задан ли у счёта ПИН-код
+func (s *Service) HasPIN(accountID int64) bool {
+	_, ok := s.pins[accountID]
+	return ok
+}
+
+//VerifyPIN проверяет ПИН-код счёта. Во время блокировки ПИН-код не проверяется
+//вовсе, чтобы его нельзя было подобрать
+func (s *Service) VerifyPIN(accountID int64, pin string) error {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	record, ok := s.pins[accountID]
+	if !ok {
+		return ErrPINNotSet
+	}
+	now := s.now()
+	if now.Before(record.lockedUntil) {
+		return fmt.Errorf("%w until %s", ErrPINLocked, record.lockedUntil.Format(time.RFC3339))
+	}
+
+	hash := pbkdf2([]byte(pin), record.salt, record.iterations, len(record.hash))
+	if hmac.Equal(hash, record.hash) {
+		record.failures = 0
+		record.lockouts = 0
+		return nil
+	}
+
+	policy := s.pinPolicy
+	if policy.MaxAttempts <= 0 {
+		policy = DefaultPINPolicy
+	}
+	record.failures++
+	if record.failures < policy.MaxAttempts {
+		return ErrWrongPIN
+	}
+	//каждая следующая блокировка вдвое длиннее предыдущей
+	lockout := policy.Lockout
+	for i := 0; i < record.lockouts; i++ {
+		lockout *= 2
+		if policy.MaxLockout > 0 && lockout >= policy.MaxLockout {
+			lockout = policy.MaxLockout
+			break
+		}
+	}
+	record.failures = 0
+	record.lockouts++
+	record.lockedUntil = now.Add(lockout)
+	return fmt.Errorf("%w until %s", ErrPINLocked, record.lockedUntil.Format(time.RFC3339))
+}
+
+//PayAuthorized выполняет Pay после проверки ПИН-кода счёта
+func (s *Service) PayAuthorized(accountID int64, pin string, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	err := s.authorize(accountID, pin)
+	if err != nil {
+		return nil, err
+	}
+	return s.Pay(accountID, amount, category)
+}
+
+//authorize проверяет ПИН-код, если он задан у счёта или передан в pin;
+//операции по счёту без ПИН-кода выполняются без проверки
+func (s *Service) authorize(accountID int64, pin string) error {
+	if pin == "" && !s.HasPIN(accountID) {
+		return nil
+	}
+	return s.VerifyPIN(accountID, pin)
+}
+
+//CloseAuthorized выполняет Close после проверки ПИН-кода закрываемого счёта
+func (s *Service) CloseAuthorized(accountID int64, pin string, payoutAccountID int64) error {
+	err := s.authorize(accountID, pin)
+	if err != nil {
+		return err
+	}
+	return s.Close(accountID, payoutAccountID)
+}
+
+//RepeatAuthorized выполняет Repeat после проверки ПИН-кода счёта платежа
+func (s *Service) RepeatAuthorized(paymentID string, pin string) (*types.Payment, error) {
+	payment, err := s.FindPaymentByID(paymentID)
+	if err == nil {
+		err = s.authorize(payment.AccountID, pin)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.Repeat(paymentID)
+}
+
+//PayFromTemplateAuthorized выполняет PayFromTemplate после проверки ПИН-кода счёта избранного
+func (s *Service) PayFromTemplateAuthorized(favoriteID string, pin string, overrides types.TemplateOverrides) (*types.Payment, error) {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.authorize(favorite.AccountID, pin)
+	if err != nil {
+		return nil, err
+	}
+	return s.PayFromTemplate(favoriteID, overrides)
+}
+
+//PayFromEnvelopeAuthorized выполняет PayFromEnvelope после проверки ПИН-кода счёта конверта
+func (s *Service) PayFromEnvelopeAuthorized(envelopeID string, pin string, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	envelope, err := s.FindEnvelopeByID(envelopeID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.authorize(envelope.AccountID, pin)
+	if err != nil {
+		return nil, err
+	}
+	return s.PayFromEnvelope(envelopeID, amount, category)
+}
+
+//ConvertAuthorized выполняет Convert после проверки ПИН-кода счёта списания
+func (s *Service) ConvertAuthorized(fromAccountID int64, toAccountID int64, pin string, amount types.Money) (*types.Conversion, error) {
+	err := s.authorize(fromAccountID, pin)
+	if err != nil {
+		return nil, err
+	}
+	return s.Convert(fromAccountID, toAccountID, amount)
+}
+
+//ChangePhoneAuthorized выполняет ChangePhone после проверки ПИН-кода счёта
+func (s *Service) ChangePhoneAuthorized(accountID int64, pin string, phone types.Phone) (*types.Account, error) {
+	err := s.authorize(accountID, pin)
+	if err != nil {
+		return nil, err
+	}
+	return s.ChangePhone(accountID, phone)
+}
+
+func validPIN(pin string) bool {
+	return len(pin) >= 4 && len(pin) <= 6 && onlyDigits(pin)
+}
+
+//pbkdf2 вычисляет ключ по RFC 8018 с HMAC-SHA256 в качестве псевдослучайной функции
+func pbkdf2(password []byte, salt []byte, iterations int, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLen)
+	block := make([]byte, 4)
+	for i := uint32(1); len(key) < keyLen; i++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(block, i)
+		prf.Write(block)
+		u := prf.Sum(nil)
+		t := make([]byte, len(u))
+		copy(t, u)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+func (s *Service) exportPINs(dir string) error {
+	records := make([][]string, 0, len(s.pins))
+	for _, account := range s.accounts {
+		record, ok := s.pins[account.ID]
+		if !ok {
+			continue
+		}
+		records = append(records, []string{
+			strconv.FormatInt(account.ID, 10),
+			hex.EncodeToString(record.salt),
+			hex.EncodeToString(record.hash),
+			strconv.Itoa(record.iterations),
+			strconv.Itoa(record.failures),
+			strconv.Itoa(record.lockouts),
+			formatTime(record.lockedUntil),
+		})
+	}
+	return writeDump(dir+"/pins.dump", records)
+}
+
+func (s *Service) importPINs(dir string) error {
+	records, err := readDump(dir + "/pins.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 7 {
+			err = fmt.Errorf("pins.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		accountID, err := strconv.ParseInt(splits[0], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		salt, err := hex.DecodeString(splits[1])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		hash, err := hex.DecodeString(splits[2])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		counters := make([]int, 3)
+		for i := range counters {
+			counters[i], err = strconv.Atoi(splits[3+i])
+			if err != nil {
+				log.Print(err)
+				return err
+			}
+		}
+		lockedUntil, err := parseTime(splits[6])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		if s.pins == nil {
+			s.pins = make(map[int64]*pinRecord)
+		}
+		s.pins[accountID] = &pinRecord{
+			salt:        salt,
+			hash:        hash,
+			iterations:  counters[0],
+			failures:    counters[1],
+			lockouts:    counters[2],
+			lockedUntil: lockedUntil,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestPBKDF2(t *testing.T) {
+	//тестовые векторы PBKDF2-HMAC-SHA256 из RFC 7914
+	tests := []struct {
+		password   string
+		salt       string
+		iterations int
+		want       string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2([]byte(tt.password), []byte(tt.salt), tt.iterations, 64))
+		if got != tt.want {
+			t.Errorf("pbkdf2(%q, %q, %d) = %s, want %s", tt.password, tt.salt, tt.iterations, got, tt.want)
+		}
+	}
+}
+
+func TestService_SetPIN(t *testing.T) {
+	s := newTestService()
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pin := range []string{"", "123", "1234567", "12a4"} {
+		err = s.SetPIN(account.ID, "", pin)
+		if err != ErrInvalidPIN {
+			t.Errorf("SetPIN(%q): must return ErrInvalidPIN, returned = %v", pin, err)
+		}
+	}
+	err = s.VerifyPIN(account.ID, "1234")
+	if err != ErrPINNotSet {
+		t.Errorf("VerifyPIN(): must return ErrPINNotSet, returned = %v", err)
+	}
+
+	err = s.SetPIN(account.ID, "", "1234")
+	if err != nil {
+		t.Fatalf("SetPIN(): error = %v", err)
+	}
+	if string(s.pins[account.ID].hash) == "1234" || len(s.pins[account.ID].salt) != pinSaltSize {
+		t.Errorf("SetPIN(): pin must be stored salted and hashed")
+	}
+	err = s.SetPIN(account.ID, "0000", "5678")
+	if err != ErrWrongPIN {
+		t.Errorf("SetPIN(): changing without current pin must return ErrWrongPIN, returned = %v", err)
+	}
+	err = s.SetPIN(account.ID, "1234", "5678")
+	if err != nil {
+		t.Fatalf("SetPIN(): error = %v", err)
+	}
+	err = s.VerifyPIN(account.ID, "5678")
+	if err != nil {
+		t.Errorf("VerifyPIN(): error = %v", err)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = imported.VerifyPIN(account.ID, "5678")
+	if err != nil {
+		t.Errorf("Import(): VerifyPIN() error = %v", err)
+	}
+}
+
+func TestService_VerifyPIN_lockout(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	s.SetPINPolicy(types.PINPolicy{MaxAttempts: 2, Lockout: time.Minute, MaxLockout: 3 * time.Minute})
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetPIN(account.ID, "", "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//блокировки: минута, две, потом не дольше трёх минут
+	for _, lockout := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		err = s.VerifyPIN(account.ID, "0000")
+		if err != ErrWrongPIN {
+			t.Fatalf("VerifyPIN(): must return ErrWrongPIN, returned = %v", err)
+		}
+		err = s.VerifyPIN(account.ID, "0000")
+		if !errors.Is(err, ErrPINLocked) {
+			t.Fatalf("VerifyPIN(): must return ErrPINLocked, returned = %v", err)
+		}
+		now = now.Add(lockout - time.Second)
+		err = s.VerifyPIN(account.ID, "1234")
+		if !errors.Is(err, ErrPINLocked) {
+			t.Errorf("VerifyPIN(): correct pin during %v lockout must return ErrPINLocked, returned = %v", lockout, err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	err = s.VerifyPIN(account.ID, "1234")
+	if err != nil {
+		t.Fatalf("VerifyPIN(): error after lockout = %v", err)
+	}
+	//успешная проверка сбрасывает удлинение блокировок
+	s.VerifyPIN(account.ID, "0000")
+	s.VerifyPIN(account.ID, "0000")
+	now = now.Add(time.Minute)
+	err = s.VerifyPIN(account.ID, "1234")
+	if err != nil {
+		t.Errorf("VerifyPIN(): lockout must be reset after success, error = %v", err)
+	}
+}
+
+func TestService_PayAuthorized(t *testing.T) {
+	s := newTestService()
+	account, _, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.PayAuthorized(account.ID, "", 100, "auto")
+	if err != nil {
+		t.Errorf("PayAuthorized(): account without PIN must pay without one, error = %v", err)
+	}
+	_, err = s.PayAuthorized(account.ID, "1234", 100, "auto")
+	if err != ErrPINNotSet {
+		t.Errorf("PayAuthorized(): must return ErrPINNotSet, returned = %v", err)
+	}
+	err = s.SetPIN(account.ID, "", "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balance := account.Balance
+	_, err = s.PayAuthorized(account.ID, "4321", 100, "auto")
+	if err != ErrWrongPIN || account.Balance != balance {
+		t.Errorf("PayAuthorized(): must return ErrWrongPIN, returned = %v, balance = %d", err, account.Balance)
+	}
+	payment, err := s.PayAuthorized(account.ID, "1234", 100, "auto")
+	if err != nil || payment.Amount != 100 {
+		t.Errorf("PayAuthorized(): payment = %v, error = %v", payment, err)
+	}
+}
+
+func TestService_authorized(t *testing.T) {
+	s := newTestService()
+	account, payments, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	favorite, err := s.FavoritePayment(payments[0].ID, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := s.CreateEnvelope(account.ID, "rent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.MoveToEnvelope(envelope.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//без ПИН-кода операции выполняются как обычно
+	_, err = s.RepeatAuthorized(payments[0].ID, "")
+	if err != nil {
+		t.Errorf("RepeatAuthorized(): error = %v", err)
+	}
+
+	err = s.SetPIN(account.ID, "", "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance := account.Balance
+	_, err = s.RepeatAuthorized(payments[0].ID, "")
+	if err != ErrWrongPIN {
+		t.Errorf("RepeatAuthorized(): must return ErrWrongPIN, returned = %v", err)
+	}
+	_, err = s.PayFromTemplateAuthorized(favorite.ID, "4321", types.TemplateOverrides{})
+	if err != ErrWrongPIN {
+		t.Errorf("PayFromTemplateAuthorized(): must return ErrWrongPIN, returned = %v", err)
+	}
+	_, err = s.PayFromEnvelopeAuthorized(envelope.ID, "", 100, "auto")
+	if !errors.Is(err, ErrPINLocked) {
+		t.Errorf("PayFromEnvelopeAuthorized(): must return ErrPINLocked, returned = %v", err)
+	}
+	if account.Balance != balance || envelope.Balance != 1_000_00 {
+		t.Errorf("balances = %v, %v, must not change", account.Balance, envelope.Balance)
+	}
+
+	s.pins[account.ID].lockedUntil = time.Time{}
+	_, err = s.PayFromEnvelopeAuthorized(envelope.ID, "1234", 100, "auto")
+	if err != nil {
+		t.Errorf("PayFromEnvelopeAuthorized(): error = %v", err)
+	}
+	_, err = s.PayFromTemplateAuthorized(favorite.ID, "1234", types.TemplateOverrides{})
+	if err != nil {
+		t.Errorf("PayFromTemplateAuthorized(): error = %v", err)
+	}
+	_, err = s.ConvertAuthorized(account.ID, 404, "", 100)
+	if err != ErrWrongPIN {
+		t.Errorf("ConvertAuthorized(): must return ErrWrongPIN, returned = %v", err)
+	}
+	_, err = s.ChangePhoneAuthorized(account.ID, "", "+992000000009")
+	if err != ErrWrongPIN {
+		t.Errorf("ChangePhoneAuthorized(): must return ErrWrongPIN, returned = %v", err)
+	}
+	_, err = s.ChangePhoneAuthorized(account.ID, "1234", "+992000000009")
+	if err != nil {
+		t.Errorf("ChangePhoneAuthorized(): error = %v", err)
+	}
+	err = s.CloseAuthorized(account.ID, "", 0)
+	if err != ErrWrongPIN || account.Status == types.AccountStatusClosed {
+		t.Errorf("CloseAuthorized(): must return ErrWrongPIN, returned = %v", err)
+	}
+}
+
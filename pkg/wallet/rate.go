@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+//RateScale - знаменатель курсов: курс 10_950_000 означает 10,95
+const RateScale = 1_000_000
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+//RateProvider возвращает курс обмена: сколько единиц to стоит одна единица from,
+//умноженное на RateScale
+type RateProvider interface {
+	Rate(from types.Currency, to types.Currency) (int64, error)
+}
+
+type currencyPair struct {
+	from types.Currency
+	to   types.Currency
+}
+
+//StaticRates - таблица курсов в памяти. Если задан только обратный курс,
+//прямой вычисляется из него
+type StaticRates struct {
+	rates map[currencyPair]int64
+}
+
+func NewStaticRates() *StaticRates {
+	return &StaticRates{rates: make(map[currencyPair]int64)}
+}
+
+//Set задаёт курс from -> to в единицах RateScale
+func (r *StaticRates) Set(from types.Currency, to types.Currency, rate int64) {
+	r.rates[currencyPair{from, to}] = rate
+}
+
+func (r *StaticRates) Rate(from types.Currency, to types.Currency) (int64, error) {
+	rate, ok := r.rates[currencyPair{from, to}]
+	if ok {
+		return rate, nil
+	}
+	inverse, ok := r.rates[currencyPair{to, from}]
+	if ok && inverse > 0 {
+		return RateScale * RateScale / inverse, nil
+	}
+	return 0, fmt.Errorf("%w: %s/%s", ErrRateNotFound, from, to)
+}
+
+//LoadRates читает таблицу курсов из файла со строками вида "USD;TJS;10.95"
+func LoadRates(path string) (*StaticRates, error) {
+	records, err := readDump(path)
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		err = fmt.Errorf("%s: %w", path, os.ErrNotExist)
+		log.Print(err)
+		return nil, err
+	}
+
+	rates := NewStaticRates()
+	for _, splits := range records {
+		if len(splits) != 3 {
+			err = fmt.Errorf("%s: wrong record %v", path, splits)
+			log.Print(err)
+			return nil, err
+		}
+		rate, err := parseRate(splits[2])
+		if err != nil {
+			log.Print(err)
+			return nil, err
+		}
+		rates.Set(types.Currency(splits[0]), types.Currency(splits[1]), rate)
+	}
+	return rates, nil
+}
+
+//parseRate переводит десятичный курс в единицы RateScale без потери точности
+func parseRate(s string) (int64, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) > 2 || parts[0] == "" {
+		return 0, fmt.Errorf("wrong rate %q", s)
+	}
+	fraction := ""
+	if len(parts) == 2 {
+		fraction = parts[1]
+	}
+	digits := len(strconv.Itoa(RateScale)) - 1
+	if len(fraction) > digits {
+		return 0, fmt.Errorf("wrong rate %q: more than %d decimal places", s, digits)
+	}
+	fraction += strings.Repeat("0", digits-len(fraction))
+	rate, err := strconv.ParseInt(parts[0]+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("wrong rate %q: %w", s, err)
+	}
+	if rate <= 0 {
+		return 0, fmt.Errorf("wrong rate %q", s)
+	}
+	return rate, nil
+}
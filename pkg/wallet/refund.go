@@ -0,0 +1,157 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/Behzod01/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrRefundExceedsPayment = errors.New("refund exceeds refundable amount")
+
+//Refund возвращает часть платежа на счёт вместе с соответствующей долей комиссии.
+//Сумма всех возвратов не может превышать сумму платежа; после Reject возвраты невозможны
+func (s *Service) Refund(paymentID string, amount types.Money) (*types.Refund, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status == types.PaymentStatusFail {
+		return nil, ErrPaymentRejected
+	}
+	if payment.Status == types.PaymentStatusPendingConfirmation {
+		return nil, ErrPaymentNotConfirmed
+	}
+	if amount > s.RefundableAmount(payment) {
+		return nil, ErrRefundExceedsPayment
+	}
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkOpen(account)
+	if err != nil {
+		return nil, err
+	}
+
+	fee, err := refundFee(payment, amount)
+	if err != nil {
+		return nil, err
+	}
+	refund := &types.Refund{
+		ID:        uuid.New().String(),
+		PaymentID: payment.ID,
+		AccountID: payment.AccountID,
+		Amount:    amount,
+		Fee:       fee,
+	}
+	clawback, err := s.cashbackClawback(payment, amount, payment.Refunded)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkClawback(payment, account, amount+refund.Fee, clawback)
+	if err != nil {
+		return nil, err
+	}
+	err = s.creditPayment(payment, account, amount+refund.Fee)
+	if err != nil {
+		return nil, err
+	}
+	s.clawbackCashback(payment, account, clawback)
+	payment.Refunded += amount
+	payment.FeeRefunded += refund.Fee
+	s.refunds = append(s.refunds, refund)
+	return refund, nil
+}
+
+//RefundableAmount возвращает сумму, которую ещё можно вернуть по платежу
+func (s *Service) RefundableAmount(payment *types.Payment) types.Money {
+	if payment.Status == types.PaymentStatusFail {
+		return 0
+	}
+	return payment.Amount - payment.Refunded
+}
+
+//FindRefundsByPaymentID возвращает возвраты по платежу в порядке их создания
+func (s *Service) FindRefundsByPaymentID(paymentID string) ([]*types.Refund, error) {
+	_, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	refunds := make([]*types.Refund, 0)
+	for _, refund := range s.refunds {
+		if refund.PaymentID == paymentID {
+			refunds = append(refunds, refund)
+		}
+	}
+	return refunds, nil
+}
+
+func (s *Service) exportRefunds(dir string) error {
+	records := make([][]string, 0, len(s.refunds))
+	for _, refund := range s.refunds {
+		records = append(records, []string{
+			refund.ID,
+			refund.PaymentID,
+			strconv.FormatInt(refund.AccountID, 10),
+			strconv.FormatInt(int64(refund.Amount), 10),
+			strconv.FormatInt(int64(refund.Fee), 10),
+		})
+	}
+	return writeDump(dir+"/refunds.dump", records)
+}
+
+//importRefunds загружает возвраты и восстанавливает возвращённые суммы платежей
+func (s *Service) importRefunds(dir string) error {
+	records, err := readDump(dir + "/refunds.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) < 4 {
+			err = fmt.Errorf("refunds.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		accountID, err := strconv.ParseInt(splits[2], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		amount, err := strconv.ParseInt(splits[3], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		fee := int64(0)
+		if len(splits) > 4 {
+			fee, err = strconv.ParseInt(splits[4], 10, 64)
+			if err != nil {
+				log.Print(err)
+				return err
+			}
+		}
+		refund := &types.Refund{
+			ID:        splits[0],
+			PaymentID: splits[1],
+			AccountID: accountID,
+			Amount:    types.Money(amount),
+			Fee:       types.Money(fee),
+		}
+		payment, err := s.FindPaymentByID(refund.PaymentID)
+		if err == nil {
+			payment.Refunded += refund.Amount
+			payment.FeeRefunded += refund.Fee
+		}
+		s.refunds = append(s.refunds, refund)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestService_Refund_partial(t *testing.T) {
+	s := newTestService()
+	account, payments, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := payments[0]
+
+	_, err = s.Refund(payment.ID, 300_00)
+	if err != nil {
+		t.Fatalf("Refund(): error = %v", err)
+	}
+	_, err = s.Refund(payment.ID, 200_00)
+	if err != nil {
+		t.Fatalf("Refund(): error = %v", err)
+	}
+	if payment.Refunded != 500_00 {
+		t.Errorf("Refund(): refunded = %v, want %v", payment.Refunded, 500_00)
+	}
+	if account.Balance != defaultTestAccount.balance-500_00 {
+		t.Errorf("Refund(): balance = %v", account.Balance)
+	}
+
+	_, err = s.Refund(payment.ID, 500_01)
+	if err != ErrRefundExceedsPayment {
+		t.Errorf("Refund(): must return ErrRefundExceedsPayment, returned = %v", err)
+	}
+
+	refunds, err := s.FindRefundsByPaymentID(payment.ID)
+	if err != nil || len(refunds) != 2 {
+		t.Errorf("FindRefundsByPaymentID(): refunds = %v, error = %v", refunds, err)
+	}
+
+	err = s.Reject(payment.ID)
+	if err != nil {
+		t.Fatalf("Reject(): error = %v", err)
+	}
+	if account.Balance != defaultTestAccount.balance {
+		t.Errorf("Reject(): must return only the remainder, balance = %v", account.Balance)
+	}
+	_, err = s.Refund(payment.ID, 1)
+	if err != ErrPaymentRejected {
+		t.Errorf("Refund(): must return ErrPaymentRejected, returned = %v", err)
+	}
+	err = s.Reject(payment.ID)
+	if err != ErrPaymentRejected {
+		t.Errorf("Reject(): second reject must return ErrPaymentRejected, returned = %v", err)
+	}
+}
+
+func TestService_Refund_fail(t *testing.T) {
+	s := newTestService()
+	_, payments, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Refund(payments[0].ID, 0)
+	if err != ErrAmountMustBePositive {
+		t.Errorf("Refund(): must return ErrAmountMustBePositive, returned = %v", err)
+	}
+	_, err = s.Refund("unknown", 1)
+	if err != ErrPaymentNotFound {
+		t.Errorf("Refund(): must return ErrPaymentNotFound, returned = %v", err)
+	}
+}
+
+func TestService_Export_refunds(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestService()
+	_, payments, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refund, err := s.Refund(payments[0].ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatalf("Export(): error = %v", err)
+	}
+
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatalf("Import(): error = %v", err)
+	}
+	payment, err := imported.FindPaymentByID(payments[0].ID)
+	if err != nil || payment.Refunded != 100 {
+		t.Errorf("Import(): payment = %v, error = %v", payment, err)
+	}
+	refunds, err := imported.FindRefundsByPaymentID(payment.ID)
+	if err != nil || len(refunds) != 1 || *refunds[0] != *refund {
+		t.Errorf("Import(): refunds = %v, error = %v", refunds, err)
+	}
+	if imported.RefundableAmount(payment) != payment.Amount-100 {
+		t.Errorf("RefundableAmount() = %v", imported.RefundableAmount(payment))
+	}
+	if payment.Status != types.PaymentStatusInProgress {
+		t.Errorf("Import(): status = %v", payment.Status)
+	}
+}
@@ -0,0 +1,267 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrCashbackSpent = errors.New("cashback already spent")
+var ErrInvalidCashbackRule = errors.New("invalid cashback rule")
+
+//SetCashbackRule задаёт кэшбэк для категории платежей, заменяя прежнее правило.
+//Правило категории действует и на вложенные в неё категории, если у них нет своего.
+//Percent должен быть от 0 до 10 000 базисных пунктов (100%)
+func (s *Service) SetCashbackRule(rule types.CashbackRule) error {
+	if rule.Percent < 0 || rule.Percent > 10_000 {
+		return ErrInvalidCashbackRule
+	}
+	category, err := s.resolveCategory(rule.Category)
+	if err != nil {
+		return err
+	}
+	rule.Category = category
+	if s.cashbackRules == nil {
+		s.cashbackRules = make(map[types.PaymentCategory]types.CashbackRule)
+	}
+	s.cashbackRules[category] = rule
+	return nil
+}
+
+//SetCashbackMonthlyCap ограничивает кэшбэк, начисляемый одному счёту за календарный месяц;
+//ноль снимает ограничение
+func (s *Service) SetCashbackMonthlyCap(limit types.Money) {
+	s.cashbackMonthlyCap = limit
+}
+
+//CompletePayment переводит платёж в статус OK и начисляет кэшбэк. Если кэшбэк
+//зачислить нельзя, например счёт заморожен, платёж остаётся незавершённым
+func (s *Service) CompletePayment(paymentID string) error {
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return err
+	}
+	switch payment.Status {
+	case types.PaymentStatusFail:
+		return ErrPaymentRejected
+	case types.PaymentStatusOk:
+		return nil
+	case types.PaymentStatusPendingConfirmation:
+		return ErrPaymentNotConfirmed
+	}
+
+	err = s.accrueCashback(payment)
+	if err != nil {
+		return err
+	}
+	payment.Status = types.PaymentStatusOk
+	return nil
+}
+
+//Rewards возвращает начисления и списания кэшбэка по счёту
+func (s *Service) Rewards(accountID int64) ([]*types.Reward, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	rewards := make([]*types.Reward, 0)
+	for _, reward := range s.rewards {
+		if reward.AccountID == accountID {
+			rewards = append(rewards, reward)
+		}
+	}
+	return rewards, nil
+}
+
+//RewardsSummary возвращает начисленный, списанный и выплаченный на счёт кэшбэк
+func (s *Service) RewardsSummary(accountID int64) (types.RewardSummary, error) {
+	rewards, err := s.Rewards(accountID)
+	if err != nil {
+		return types.RewardSummary{}, err
+	}
+
+	summary := types.RewardSummary{}
+	for _, reward := range rewards {
+		if reward.Amount > 0 {
+			summary.Accrued += reward.Amount
+		} else {
+			summary.ClawedBack -= reward.Amount
+		}
+	}
+	summary.Paid = summary.Accrued - summary.ClawedBack
+	return summary, nil
+}
+
+func (s *Service) accrueCashback(payment *types.Payment) error {
+	rule, ok := s.cashbackRule(payment.Category)
+	if !ok {
+		return nil
+	}
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return err
+	}
+
+	//кэшбэк считается от невозвращённой части платежа с банковским округлением
+	cashback, err := (payment.Amount - payment.Refunded).Percent(int64(rule.Percent))
+	if err != nil {
+		return err
+	}
+	if s.cashbackMonthlyCap > 0 {
+		left := s.cashbackMonthlyCap - s.monthlyCashback(account.ID, s.now())
+		if cashback > left {
+			cashback = left
+		}
+	}
+	if cashback <= 0 {
+		return nil
+	}
+
+	err = s.credit(account, cashback)
+	if err != nil {
+		return err
+	}
+	s.addReward(payment, cashback)
+	return nil
+}
+
+//cashbackRule ищет правило кэшбэка категории, а если его нет - ближайшего родителя
+func (s *Service) cashbackRule(category types.PaymentCategory) (types.CashbackRule, bool) {
+	for _, code := range s.categoryPath(category) {
+		rule, ok := s.cashbackRules[code]
+		if ok {
+			return rule, true
+		}
+	}
+	return types.CashbackRule{}, false
+}
+
+//cashbackClawback возвращает кэшбэк, приходящийся на возвращаемую сумму платежа;
+//refunded - часть платежа, возвращённая до этой операции
+func (s *Service) cashbackClawback(payment *types.Payment, amount types.Money, refunded types.Money) (types.Money, error) {
+	left := types.Money(0)
+	for _, reward := range s.rewards {
+		if reward.PaymentID == payment.ID {
+			left += reward.Amount
+		}
+	}
+	if left <= 0 {
+		return 0, nil
+	}
+
+	clawback := left
+	if remainder := payment.Amount - refunded; amount < remainder {
+		var err error
+		clawback, err = left.MulDiv(int64(amount), int64(remainder))
+		if err != nil {
+			return 0, err
+		}
+	}
+	if clawback <= 0 {
+		return 0, nil
+	}
+	return clawback, nil
+}
+
+//checkClawback проверяет, что кэшбэк можно списать со счёта после зачисления credit.
+//Если кэшбэк уже потрачен, операция отклоняется целиком, а не уводит баланс в минус
+func (s *Service) checkClawback(payment *types.Payment, account *types.Account, credit types.Money, clawback types.Money) error {
+	//возврат платежа из конверта зачисляется в конверт, а кэшбэк списывается со счёта
+	_, err := s.FindEnvelopeByID(payment.EnvelopeID)
+	if err == nil {
+		credit = 0
+	}
+	if account.Balance+credit < clawback {
+		return fmt.Errorf("%w: %d to claw back", ErrCashbackSpent, clawback)
+	}
+	return nil
+}
+
+//clawbackCashback списывает проверенный checkClawback кэшбэк
+func (s *Service) clawbackCashback(payment *types.Payment, account *types.Account, clawback types.Money) {
+	if clawback <= 0 {
+		return
+	}
+	account.Balance -= clawback
+	s.addReward(payment, -clawback)
+}
+
+func (s *Service) addReward(payment *types.Payment, amount types.Money) {
+	s.rewards = append(s.rewards, &types.Reward{
+		ID:        uuid.New().String(),
+		AccountID: payment.AccountID,
+		PaymentID: payment.ID,
+		Amount:    amount,
+		CreatedAt: s.now(),
+	})
+}
+
+//monthlyCashback возвращает кэшбэк счёта за календарный месяц, содержащий момент at
+func (s *Service) monthlyCashback(accountID int64, at time.Time) types.Money {
+	year, month, _ := at.Date()
+	sum := types.Money(0)
+	for _, reward := range s.rewards {
+		rewardYear, rewardMonth, _ := reward.CreatedAt.In(at.Location()).Date()
+		if reward.AccountID == accountID && rewardYear == year && rewardMonth == month {
+			sum += reward.Amount
+		}
+	}
+	return sum
+}
+
+func (s *Service) exportRewards(dir string) error {
+	records := make([][]string, 0, len(s.rewards))
+	for _, reward := range s.rewards {
+		records = append(records, []string{
+			reward.ID,
+			strconv.FormatInt(reward.AccountID, 10),
+			reward.PaymentID,
+			strconv.FormatInt(int64(reward.Amount), 10),
+			strconv.FormatInt(reward.CreatedAt.Unix(), 10),
+		})
+	}
+	return writeDump(dir+"/rewards.dump", records)
+}
+
+func (s *Service) importRewards(dir string) error {
+	records, err := readDump(dir + "/rewards.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 5 {
+			err = fmt.Errorf("rewards.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		accountID, err := strconv.ParseInt(splits[1], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		amount, err := strconv.ParseInt(splits[3], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		createdAt, err := strconv.ParseInt(splits[4], 10, 64)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		s.rewards = append(s.rewards, &types.Reward{
+			ID:        splits[0],
+			AccountID: accountID,
+			PaymentID: splits[2],
+			Amount:    types.Money(amount),
+			CreatedAt: time.Unix(createdAt, 0),
+		})
+	}
+	return nil
+}
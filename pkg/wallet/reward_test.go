@@ -0,0 +1,189 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestService_CompletePayment_cashback(t *testing.T) {
+	s := newTestService()
+	s.SetCashbackRule(types.CashbackRule{Category: "cafe", Percent: 200})
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := s.Pay(account.ID, 500_00, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 500_00 {
+		t.Errorf("Pay(): cashback must wait for OK status, balance = %v", account.Balance)
+	}
+	err = s.CompletePayment(payment.ID)
+	if err != nil {
+		t.Fatalf("CompletePayment(): error = %v", err)
+	}
+	if payment.Status != types.PaymentStatusOk || account.Balance != 510_00 {
+		t.Errorf("CompletePayment(): status = %v, balance = %v", payment.Status, account.Balance)
+	}
+	err = s.CompletePayment(payment.ID)
+	if err != nil || account.Balance != 510_00 {
+		t.Errorf("CompletePayment(): second call must not accrue again, balance = %v, error = %v", account.Balance, err)
+	}
+
+	_, err = s.Refund(payment.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 608_00 {
+		t.Errorf("Refund(): cashback must be clawed back proportionally, balance = %v", account.Balance)
+	}
+
+	err = s.Reject(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 1_000_00 {
+		t.Errorf("Reject(): cashback must be clawed back, balance = %v", account.Balance)
+	}
+	summary, err := s.RewardsSummary(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != (types.RewardSummary{Accrued: 10_00, ClawedBack: 10_00, Paid: 0}) {
+		t.Errorf("RewardsSummary() = %v", summary)
+	}
+	err = s.CompletePayment(payment.ID)
+	if err != ErrPaymentRejected {
+		t.Errorf("CompletePayment(): must return ErrPaymentRejected, returned = %v", err)
+	}
+}
+
+func TestService_SetCashbackRule_invalid(t *testing.T) {
+	s := newTestService()
+	for _, percent := range []int64{-1, 10_001} {
+		err := s.SetCashbackRule(types.CashbackRule{Category: "auto", Percent: percent})
+		if err != ErrInvalidCashbackRule {
+			t.Errorf("SetCashbackRule(%d): must return ErrInvalidCashbackRule, returned = %v", percent, err)
+		}
+	}
+}
+
+func TestService_Reject_cashbackSpent(t *testing.T) {
+	s := newTestService()
+	s.SetCashbackRule(types.CashbackRule{Category: "cafe", Percent: 200})
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := s.CreateEnvelope(account.ID, "food")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.MoveToEnvelope(envelope.ID, 500_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := s.PayFromEnvelope(envelope.ID, 500_00, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.CompletePayment(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//кэшбэк пришёл на счёт и потрачен, а возврат уходит в конверт
+	_, err = s.Pay(account.ID, 510_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Reject(payment.ID)
+	if !errors.Is(err, ErrCashbackSpent) {
+		t.Errorf("Reject(): must return ErrCashbackSpent, returned = %v", err)
+	}
+	_, err = s.Refund(payment.ID, 100_00)
+	if !errors.Is(err, ErrCashbackSpent) {
+		t.Errorf("Refund(): must return ErrCashbackSpent, returned = %v", err)
+	}
+	if account.Balance != 0 || envelope.Balance != 0 || payment.Status != types.PaymentStatusOk {
+		t.Errorf("rejected clawback must not change anything, balance = %v, envelope = %v, status = %v", account.Balance, envelope.Balance, payment.Status)
+	}
+
+	err = s.Deposit(account.ID, 10_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Reject(payment.ID)
+	if err != nil || account.Balance != 0 || envelope.Balance != 500_00 {
+		t.Errorf("Reject(): balance = %v, envelope = %v, error = %v", account.Balance, envelope.Balance, err)
+	}
+}
+
+func TestService_CompletePayment_monthlyCap(t *testing.T) {
+	now := time.Date(2022, 1, 31, 12, 0, 0, 0, time.UTC)
+	s := newTestService()
+	s.SetClock(func() time.Time { return now })
+	s.SetCashbackRule(types.CashbackRule{Category: "cafe", Percent: 1_000})
+	s.SetCashbackMonthlyCap(15_00)
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pay := func() {
+		payment, err := s.Pay(account.ID, 100_00, "cafe")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.CompletePayment(payment.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	pay()
+	pay()
+	pay()
+	summary, _ := s.RewardsSummary(account.ID)
+	if summary.Paid != 15_00 {
+		t.Errorf("monthly cap: paid = %v, want %v", summary.Paid, 15_00)
+	}
+
+	now = now.Add(24 * time.Hour)
+	pay()
+	summary, _ = s.RewardsSummary(account.ID)
+	if summary.Paid != 25_00 {
+		t.Errorf("next month: paid = %v, want %v", summary.Paid, 25_00)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatalf("Import(): error = %v", err)
+	}
+	rewards, err := imported.Rewards(account.ID)
+	if err != nil || len(rewards) != 3 {
+		t.Errorf("Import(): rewards = %v, error = %v", rewards, err)
+	}
+}
@@ -0,0 +1,312 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+//SchedulePayment создаёт расписание платежей по избранному
+func (s *Service) SchedulePayment(favoriteID string, rule types.ScheduleRule) (*types.Schedule, error) {
+	_, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+	if rule.Start.IsZero() {
+		return nil, ErrInvalidSchedule
+	}
+	switch rule.Kind {
+	case types.ScheduleOnce, types.ScheduleDaily, types.ScheduleWeekly, types.ScheduleMonthly:
+	case types.ScheduleBusinessDay:
+		if rule.BusinessDay < 1 {
+			return nil, ErrInvalidSchedule
+		}
+	default:
+		return nil, ErrInvalidSchedule
+	}
+
+	due := rule.Start
+	if rule.Kind == types.ScheduleBusinessDay {
+		due = nthBusinessDay(rule.Start.Year(), rule.Start.Month(), rule.BusinessDay, rule.Start)
+		if due.Before(rule.Start) {
+			due, _ = nextOccurrence(rule, due)
+		}
+	}
+
+	schedule := &types.Schedule{
+		ID:         uuid.New().String(),
+		FavoriteID: favoriteID,
+		Rule:       rule,
+		Due:        due,
+		NextRun:    due,
+		Active:     true,
+	}
+	s.schedules = append(s.schedules, schedule)
+	return schedule, nil
+}
+
+//FindScheduleByID ищет расписание по идентификатору
+func (s *Service) FindScheduleByID(scheduleID string) (*types.Schedule, error) {
+	for _, schedule := range s.schedules {
+		if schedule.ID == scheduleID {
+			return schedule, nil
+		}
+	}
+	return nil, ErrScheduleNotFound
+}
+
+//CancelSchedule отключает расписание; история его платежей сохраняется
+func (s *Service) CancelSchedule(scheduleID string) error {
+	schedule, err := s.FindScheduleByID(scheduleID)
+	if err != nil {
+		return err
+	}
+	schedule.Active = false
+	return nil
+}
+
+//SetRetryPolicy задаёт повторы неудавшихся платежей по расписанию.
+//По умолчанию платёж не повторяется и переносится на следующую дату расписания
+func (s *Service) SetRetryPolicy(policy types.RetryPolicy) {
+	s.retryPolicy = policy
+}
+
+//ScheduledRuns возвращает историю попыток платежей по расписанию
+func (s *Service) ScheduledRuns(scheduleID string) ([]*types.ScheduledRun, error) {
+	_, err := s.FindScheduleByID(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]*types.ScheduledRun, 0)
+	for _, run := range s.scheduledRuns {
+		if run.ScheduleID == scheduleID {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+//RunScheduledPayments выполняет платежи, время которых наступило по часам сервиса.
+//Каждое расписание выполняется не более одного раза за вызов; пропущенные даты не наверстываются
+func (s *Service) RunScheduledPayments() []*types.ScheduledRun {
+	now := s.now()
+	runs := make([]*types.ScheduledRun, 0)
+	for _, schedule := range s.schedules {
+		if !schedule.Active || schedule.NextRun.After(now) {
+			continue
+		}
+
+		run := &types.ScheduledRun{ScheduleID: schedule.ID, At: now}
+		payment, err := s.PayFromFavorite(schedule.FavoriteID)
+		switch {
+		case err == nil:
+			run.PaymentID = payment.ID
+			schedule.Attempts = 0
+			advanceSchedule(schedule, now)
+		case errors.Is(err, ErrFavoriteNotFound):
+			run.Error = err.Error()
+			schedule.Active = false
+		default:
+			run.Error = err.Error()
+			schedule.Attempts++
+			if schedule.Attempts < s.retryPolicy.MaxAttempts {
+				schedule.NextRun = now.Add(s.retryPolicy.Delay)
+			} else {
+				schedule.Attempts = 0
+				advanceSchedule(schedule, now)
+			}
+		}
+
+		s.scheduledRuns = append(s.scheduledRuns, run)
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+//advanceSchedule переносит расписание на первую дату после now или отключает его
+func advanceSchedule(schedule *types.Schedule, now time.Time) {
+	due := schedule.Due
+	for !due.After(now) {
+		next, ok := nextOccurrence(schedule.Rule, due)
+		if !ok {
+			schedule.Active = false
+			return
+		}
+		due = next
+	}
+	schedule.Due = due
+	schedule.NextRun = due
+}
+
+//nextOccurrence возвращает следующую после due дату расписания
+func nextOccurrence(rule types.ScheduleRule, due time.Time) (time.Time, bool) {
+	switch rule.Kind {
+	case types.ScheduleDaily:
+		return due.AddDate(0, 0, 1), true
+	case types.ScheduleWeekly:
+		return due.AddDate(0, 0, 7), true
+	case types.ScheduleMonthly:
+		year, month := nextMonth(due)
+		return monthDay(year, month, rule.Start.Day(), rule.Start), true
+	case types.ScheduleBusinessDay:
+		year, month := nextMonth(due)
+		return nthBusinessDay(year, month, rule.BusinessDay, rule.Start), true
+	}
+	return time.Time{}, false
+}
+
+func nextMonth(t time.Time) (int, time.Month) {
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).AddDate(0, 1, 0)
+	return first.Year(), first.Month()
+}
+
+//monthDay возвращает указанный день месяца со временем суток из clock;
+//в коротких месяцах берётся последний день
+func monthDay(year int, month time.Month, day int, clock time.Time) time.Time {
+	first := time.Date(year, month, 1, clock.Hour(), clock.Minute(), clock.Second(), 0, clock.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+//nthBusinessDay возвращает N-й рабочий день месяца со временем суток из clock;
+//если рабочих дней меньше N, берётся последний рабочий день
+func nthBusinessDay(year int, month time.Month, n int, clock time.Time) time.Time {
+	var last time.Time
+	count := 0
+	for day := monthDay(year, month, 1, clock); day.Month() == month; day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		count++
+		last = day
+		if count == n {
+			break
+		}
+	}
+	return last
+}
+
+func (s *Service) exportSchedules(dir string) error {
+	records := make([][]string, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		records = append(records, []string{
+			schedule.ID,
+			schedule.FavoriteID,
+			string(schedule.Rule.Kind),
+			formatTime(schedule.Rule.Start),
+			strconv.Itoa(schedule.Rule.BusinessDay),
+			formatTime(schedule.Due),
+			formatTime(schedule.NextRun),
+			strconv.Itoa(schedule.Attempts),
+			strconv.FormatBool(schedule.Active),
+		})
+	}
+	err := writeDump(dir+"/schedules.dump", records)
+	if err != nil {
+		return err
+	}
+
+	records = make([][]string, 0, len(s.scheduledRuns))
+	for _, run := range s.scheduledRuns {
+		records = append(records, []string{
+			run.ScheduleID,
+			formatTime(run.At),
+			run.PaymentID,
+			escape(run.Error),
+		})
+	}
+	return writeDump(dir+"/scheduled_runs.dump", records)
+}
+
+func (s *Service) importSchedules(dir string) error {
+	records, err := readDump(dir + "/schedules.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 9 {
+			err = fmt.Errorf("schedules.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		//даты хранятся со смещением часового пояса: дни месяца и время суток не сдвигаются
+		times := make([]time.Time, 0, 3)
+		for _, i := range []int{3, 5, 6} {
+			t, err := parseTime(splits[i])
+			if err != nil {
+				log.Print(err)
+				return err
+			}
+			times = append(times, t)
+		}
+		businessDay, err := strconv.Atoi(splits[4])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		attempts, err := strconv.Atoi(splits[7])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		active, err := strconv.ParseBool(splits[8])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		s.schedules = append(s.schedules, &types.Schedule{
+			ID:         splits[0],
+			FavoriteID: splits[1],
+			Rule: types.ScheduleRule{
+				Kind:        types.ScheduleKind(splits[2]),
+				Start:       times[0],
+				BusinessDay: businessDay,
+			},
+			Due:      times[1],
+			NextRun:  times[2],
+			Attempts: attempts,
+			Active:   active,
+		})
+	}
+
+	records, err = readDump(dir + "/scheduled_runs.dump")
+	if err != nil {
+		return err
+	}
+	for _, splits := range records {
+		if len(splits) != 4 {
+			err = fmt.Errorf("scheduled_runs.dump: wrong record %v", splits)
+			log.Print(err)
+			return err
+		}
+		at, err := parseTime(splits[1])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		runErr, err := unescape(splits[3])
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		s.scheduledRuns = append(s.scheduledRuns, &types.ScheduledRun{
+			ScheduleID: splits[0],
+			At:         at,
+			PaymentID:  splits[2],
+			Error:      runErr,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func newScheduleTestService(t *testing.T, balance types.Money) (*testService, *types.Favorite, *time.Time) {
+	t.Helper()
+	now := time.Date(2022, 1, 1, 9, 0, 0, 0, time.UTC)
+	s := newTestService()
+	s.SetClock(func() time.Time { return now })
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := s.Pay(account.ID, 100_00, "mobile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	favorite, err := s.FavoritePayment(payment.ID, "mobile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, favorite, &now
+}
+
+func TestService_RunScheduledPayments_monthly(t *testing.T) {
+	s, favorite, now := newScheduleTestService(t, 1_000_00)
+	schedule, err := s.SchedulePayment(favorite.ID, types.ScheduleRule{
+		Kind:  types.ScheduleMonthly,
+		Start: time.Date(2022, 1, 31, 10, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("SchedulePayment(): error = %v", err)
+	}
+
+	*now = time.Date(2022, 1, 31, 9, 59, 0, 0, time.UTC)
+	if runs := s.RunScheduledPayments(); len(runs) != 0 {
+		t.Errorf("RunScheduledPayments(): payment is not due yet, runs = %v", runs)
+	}
+
+	*now = time.Date(2022, 1, 31, 10, 0, 0, 0, time.UTC)
+	runs := s.RunScheduledPayments()
+	if len(runs) != 1 || runs[0].PaymentID == "" {
+		t.Fatalf("RunScheduledPayments(): runs = %v", runs)
+	}
+	want := time.Date(2022, 2, 28, 10, 0, 0, 0, time.UTC)
+	if !schedule.NextRun.Equal(want) {
+		t.Errorf("RunScheduledPayments(): next run = %v, want %v", schedule.NextRun, want)
+	}
+
+	*now = want
+	s.RunScheduledPayments()
+	want = time.Date(2022, 3, 31, 10, 0, 0, 0, time.UTC)
+	if !schedule.NextRun.Equal(want) {
+		t.Errorf("RunScheduledPayments(): next run = %v, want %v", schedule.NextRun, want)
+	}
+}
+
+func TestService_RunScheduledPayments_retry(t *testing.T) {
+	s, favorite, now := newScheduleTestService(t, 150_00)
+	s.SetRetryPolicy(types.RetryPolicy{MaxAttempts: 2, Delay: time.Hour})
+	schedule, err := s.SchedulePayment(favorite.ID, types.ScheduleRule{
+		Kind:  types.ScheduleDaily,
+		Start: *now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs := s.RunScheduledPayments()
+	if len(runs) != 1 || runs[0].Error != ErrNotEnoughBalance.Error() {
+		t.Fatalf("RunScheduledPayments(): must record failure, runs = %v", runs)
+	}
+	if !schedule.NextRun.Equal(now.Add(time.Hour)) {
+		t.Errorf("RunScheduledPayments(): retry = %v", schedule.NextRun)
+	}
+
+	*now = now.Add(time.Hour)
+	s.RunScheduledPayments()
+	want := time.Date(2022, 1, 2, 9, 0, 0, 0, time.UTC)
+	if !schedule.NextRun.Equal(want) || schedule.Attempts != 0 {
+		t.Errorf("RunScheduledPayments(): after last attempt next run = %v, attempts = %d", schedule.NextRun, schedule.Attempts)
+	}
+
+	account, _ := s.FindAccountByID(favorite.AccountID)
+	err = s.Deposit(account.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*now = want
+	runs = s.RunScheduledPayments()
+	if len(runs) != 1 || runs[0].Error != "" {
+		t.Errorf("RunScheduledPayments(): runs = %v", runs)
+	}
+
+	history, err := s.ScheduledRuns(schedule.ID)
+	if err != nil || len(history) != 3 {
+		t.Errorf("ScheduledRuns(): runs = %v, error = %v", history, err)
+	}
+}
+
+func TestService_SchedulePayment_businessDay(t *testing.T) {
+	s, favorite, now := newScheduleTestService(t, 1_000_00)
+	//1 января 2022 - суббота, третий рабочий день - среда 5 января
+	schedule, err := s.SchedulePayment(favorite.ID, types.ScheduleRule{
+		Kind:        types.ScheduleBusinessDay,
+		Start:       *now,
+		BusinessDay: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2022, 1, 5, 9, 0, 0, 0, time.UTC)
+	if !schedule.NextRun.Equal(want) {
+		t.Errorf("SchedulePayment(): next run = %v, want %v", schedule.NextRun, want)
+	}
+
+	*now = want
+	s.RunScheduledPayments()
+	want = time.Date(2022, 2, 3, 9, 0, 0, 0, time.UTC)
+	if !schedule.NextRun.Equal(want) {
+		t.Errorf("RunScheduledPayments(): next run = %v, want %v", schedule.NextRun, want)
+	}
+
+	_, err = s.SchedulePayment(favorite.ID, types.ScheduleRule{Kind: types.ScheduleBusinessDay, Start: *now})
+	if err != ErrInvalidSchedule {
+		t.Errorf("SchedulePayment(): must return ErrInvalidSchedule, returned = %v", err)
+	}
+}
+
+func TestService_RunScheduledPayments_once(t *testing.T) {
+	s, favorite, now := newScheduleTestService(t, 1_000_00)
+	schedule, err := s.SchedulePayment(favorite.ID, types.ScheduleRule{Kind: types.ScheduleOnce, Start: *now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RunScheduledPayments()
+	if schedule.Active {
+		t.Errorf("RunScheduledPayments(): one-off schedule must be deactivated")
+	}
+	*now = now.AddDate(0, 1, 0)
+	if runs := s.RunScheduledPayments(); len(runs) != 0 {
+		t.Errorf("RunScheduledPayments(): runs = %v", runs)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatalf("Import(): error = %v", err)
+	}
+	got, err := imported.FindScheduleByID(schedule.ID)
+	if err != nil || got.Active || !got.Due.Equal(schedule.Due) {
+		t.Errorf("Import(): schedule = %v, error = %v", got, err)
+	}
+	runs, err := imported.ScheduledRuns(schedule.ID)
+	if err != nil || len(runs) != 1 {
+		t.Errorf("Import(): runs = %v, error = %v", runs, err)
+	}
+}
+
+func TestService_Import_scheduleLocation(t *testing.T) {
+	s, favorite, now := newScheduleTestService(t, 1_000_00)
+	dushanbe := time.FixedZone("TJT", 5*60*60)
+	_, err := s.SchedulePayment(favorite.ID, types.ScheduleRule{
+		Kind:  types.ScheduleMonthly,
+		Start: time.Date(2022, 1, 31, 0, 30, 0, 0, dushanbe),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	imported.SetClock(func() time.Time { return *now })
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//после перезапуска день месяца и время суток считаются в исходном часовом поясе
+	*now = time.Date(2022, 1, 31, 0, 30, 0, 0, dushanbe)
+	runs := imported.RunScheduledPayments()
+	if len(runs) != 1 {
+		t.Fatalf("RunScheduledPayments(): runs = %v", runs)
+	}
+	schedule := imported.schedules[0]
+	want := time.Date(2022, 2, 28, 0, 30, 0, 0, dushanbe)
+	if !schedule.NextRun.Equal(want) {
+		t.Errorf("RunScheduledPayments(): next run = %v, want %v", schedule.NextRun, want)
+	}
+}
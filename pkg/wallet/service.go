@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Behzod01/wallet/pkg/types"
 	"github.com/google/uuid"
@@ -32,25 +33,127 @@ var ErrAmountMustBePositive = errors.New("amount must be greater than zero")
 var ErrPaymentNotFound = errors.New("payment not found")
 var ErrNotEnoughBalance = errors.New("not enough balance")
 var ErrFavoriteNotFound = errors.New("favorite not found")
+var ErrPaymentRejected = errors.New("payment already rejected")
+
+//errorCodes - стабильные коды ошибок, которые могут вернуть Pay и Deposit. По коду
+//ошибка восстанавливается из дампа ключей идемпотентности, поэтому коды не меняются,
+//даже если меняется текст ошибки
+var errorCodes = map[error]string{
+	ErrAccountNotFound:      "account_not_found",
+	ErrPhoneRegistered:      "phone_registered",
+	ErrAmountMustBePositive: "amount_must_be_positive",
+	ErrPaymentNotFound:      "payment_not_found",
+	ErrNotEnoughBalance:     "not_enough_balance",
+	ErrFavoriteNotFound:     "favorite_not_found",
+	ErrPaymentRejected:      "payment_rejected",
+	ErrAccountFrozen:        "account_frozen",
+	ErrAccountClosed:        "account_closed",
+	ErrCurrencyMismatch:     "currency_mismatch",
+	ErrUnsupportedCurrency:  "unsupported_currency",
+	ErrCategoryNotFound:     "category_not_found",
+	ErrInvalidCategory:      "invalid_category",
+	ErrUnknownCategory:      "unknown_category",
+	ErrLimitExceeded:        "limit_exceeded",
+	ErrTierLimitExceeded:    "tier_limit_exceeded",
+	ErrNoCodeNotifier:       "no_code_notifier",
+	ErrWrongPIN:             "wrong_pin",
+	ErrPINLocked:            "pin_locked",
+	ErrPINNotSet:            "pin_not_set",
+	ErrEnvelopeNotFound:     "envelope_not_found",
+	ErrCashbackSpent:        "cashback_spent",
+	types.ErrMoneyOverflow:  "money_overflow",
+	types.ErrInvalidMoney:   "invalid_money",
+}
 
 type Service struct {
 	nextAccountID int64
 	accounts      []*types.Account
 	payments      []*types.Payment
 	favorites     []*types.Favorite
+	refunds       []*types.Refund
+	feeRules      map[types.PaymentCategory]types.FeeRule
+	cashbackRules map[types.PaymentCategory]types.CashbackRule
+	//cashbackMonthlyCap - предел кэшбэка на счёт за месяц, ноль - без предела
+	cashbackMonthlyCap types.Money
+	rewards            []*types.Reward
+	schedules          []*types.Schedule
+	scheduledRuns      []*types.ScheduledRun
+	retryPolicy        types.RetryPolicy
+	clock              Clock
+	idempotencyTTL     time.Duration
+	idempotency        []*idempotencyRecord
+	limits             map[int64]types.Limits
+	budgets            []*types.Budget
+	budgetAlert        BudgetAlertHandler
+	categories         []*types.Category
+	strictCategories   bool
+	envelopes          []*types.Envelope
+	goals              []*types.Goal
+	goalCompleted      GoalCompletedHandler
+	rates              RateProvider
+	conversionSpread   int64
+	conversions        []*types.Conversion
+	phoneChanges       []*types.PhoneChange
+	profiles           map[int64]types.Profile
+	tierLimits         map[types.KYCTier]types.TierLimits
+	upgrades           []*types.UpgradeRequest
+	deposited          map[int64]monthlyTotal
+	pinPolicy          types.PINPolicy
+	pins               map[int64]*pinRecord
+	confirmationPolicy types.ConfirmationPolicy
+	codeNotifier       CodeNotifier
+	confirmationCodes  map[string][]byte
+	confirmationKey    []byte
+}
+
+//Clock возвращает текущее время; позволяет подменять время в тестах
+type Clock func() time.Time
+
+//SetClock задаёт источник времени для сервиса; nil означает time.Now
+func (s *Service) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+//Now возвращает текущее время по часам сервиса
+func (s *Service) Now() time.Time {
+	return s.now()
+}
+
+func (s *Service) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock()
 }
 
 func (s *Service) RegisterAccount(phone types.Phone) (*types.Account, error) {
+	return s.RegisterAccountInCurrency(phone, types.DefaultCurrency)
+}
+
+//RegisterAccountInCurrency открывает счёт в указанной валюте.
+//Номер приводится к E.164; на один телефон можно открыть по одному счёту в каждой валюте
+func (s *Service) RegisterAccountInCurrency(phone types.Phone, currency types.Currency) (*types.Account, error) {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := currency.Exponent()
+	if !ok {
+		return nil, ErrUnsupportedCurrency
+	}
 	for _, account := range s.accounts {
-		if account.Phone == phone {
+		if account.Phone == phone && account.Currency == currency {
 			return nil, ErrPhoneRegistered
 		}
 	}
 	s.nextAccountID++
 	account := &types.Account{
-		ID:      s.nextAccountID,
-		Phone:   phone,
-		Balance: 0,
+		ID:       s.nextAccountID,
+		Phone:    phone,
+		Balance:  0,
+		Currency: currency,
+		Status:   types.AccountStatusActive,
+		Tier:     types.KYCTierAnonymous,
 	}
 	s.accounts = append(s.accounts, account)
 	return account, nil
@@ -72,13 +175,40 @@ func (s *Service) Deposit(accountID int64, amount types.Money) error {
 	if account == nil {
 		return ErrAccountNotFound
 	}
+	err := checkActive(account)
+	if err != nil {
+		return err
+	}
+	err = s.checkTier(account, amount)
+	if err != nil {
+		return err
+	}
 
 	//zachislenie sredstv poka ne rasmatrivaem kak platezh
-	account.Balance += amount
+	err = s.credit(account, amount)
+	if err != nil {
+		return err
+	}
+	s.recordDeposit(accountID, amount)
+	return nil
+}
+
+//addMoney зачисляет amount на баланс; при переполнении баланс не меняется
+func addMoney(balance *types.Money, amount types.Money) error {
+	sum, err := balance.Add(amount)
+	if err != nil {
+		return err
+	}
+	*balance = sum
 	return nil
 }
 
 func (s *Service) Pay(accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	return s.pay(accountID, amount, category, nil, nil)
+}
+
+//pay списывает платёж с основного баланса счёта или, если envelope не nil, с конверта
+func (s *Service) pay(accountID int64, amount types.Money, category types.PaymentCategory, envelope *types.Envelope, details map[string]string) (*types.Payment, error) {
 	if amount <= 0 {
 		return nil, ErrAmountMustBePositive
 	}
@@ -93,22 +223,103 @@ func (s *Service) Pay(accountID int64, amount types.Money, category types.Paymen
 	if account == nil {
 		return nil, ErrAccountNotFound
 	}
-	if account.Balance < amount {
+	err := checkActive(account)
+	if err != nil {
+		return nil, err
+	}
+	category, err = s.resolveCategory(category)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := s.fee(amount, category)
+	if err != nil {
+		return nil, err
+	}
+	total, err := amount.Add(fee)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkLimits(account, total, category)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkTier(account, total)
+	if err != nil {
+		return nil, err
+	}
+	balance := &account.Balance
+	envelopeID := ""
+	if envelope != nil {
+		balance = &envelope.Balance
+		envelopeID = envelope.ID
+	}
+	if *balance < total {
 		return nil, ErrNotEnoughBalance
 	}
-	account.Balance -= amount
+	if s.requiresConfirmation(amount) && s.codeNotifier == nil {
+		return nil, ErrNoCodeNotifier
+	}
+	*balance -= total
 	paymentID := uuid.New().String()
 	payment := &types.Payment{
-		ID:        paymentID,
-		AccountID: accountID,
-		Amount:    amount,
-		Category:  category,
-		Status:    types.PaymentStatusInProgress,
+		ID:         paymentID,
+		AccountID:  accountID,
+		Amount:     amount,
+		Currency:   account.Currency,
+		Category:   category,
+		Status:     types.PaymentStatusInProgress,
+		Fee:        fee,
+		CreatedAt:  s.now(),
+		EnvelopeID: envelopeID,
+		Details:    details,
 	}
 	s.payments = append(s.payments, payment)
+	//крупный платёж ждёт подтверждения кодом, а если код не удалось отправить, отменяется
+	if s.requiresConfirmation(amount) {
+		err = s.issueChallenge(account, payment)
+		if err != nil {
+			cancelErr := s.cancelConfirmation(payment)
+			if cancelErr != nil {
+				return nil, cancelErr
+			}
+			return nil, err
+		}
+		return payment, nil
+	}
+	s.settlePayment(account, payment)
 	return payment, nil
 }
 
+//settlePayment уведомляет о бюджете и округляет проведённый платёж в копилку.
+//Для платежа, ждущего подтверждения, это делает ConfirmPayment
+func (s *Service) settlePayment(account *types.Account, payment *types.Payment) {
+	s.notifyBudget(payment)
+	if payment.EnvelopeID == "" {
+		s.roundUp(account, payment)
+	}
+}
+
+//Accounts возвращает все зарегистрированные счета
+func (s *Service) Accounts() []*types.Account {
+	accounts := make([]*types.Account, len(s.accounts))
+	copy(accounts, s.accounts)
+	return accounts
+}
+
+//Payments возвращает все платежи
+func (s *Service) Payments() []*types.Payment {
+	payments := make([]*types.Payment, len(s.payments))
+	copy(payments, s.payments)
+	return payments
+}
+
+//Favorites возвращает все избранные платежи
+func (s *Service) Favorites() []*types.Favorite {
+	favorites := make([]*types.Favorite, len(s.favorites))
+	copy(favorites, s.favorites)
+	return favorites
+}
+
 func (s *Service) FindAccountByID(accountID int64) (*types.Account, error) {
 	for _, account := range s.accounts {
 		if account.ID == accountID {
@@ -136,19 +347,48 @@ func (s *Service) Reject(paymentID string) error {
 	if err != nil {
 		return err
 	}
+	err = checkOpen(account)
+	if err != nil {
+		return err
+	}
+	return s.reject(payment, account)
+}
+
+//reject отменяет платёж и возвращает деньги на счёт без проверки статуса счёта
+func (s *Service) reject(payment *types.Payment, account *types.Account) error {
+	if payment.Status == types.PaymentStatusFail {
+		return ErrPaymentRejected
+	}
+	//частично возвращённая сумма и её доля комиссии уже зачислены на счёт
+	credit := payment.Amount - payment.Refunded + payment.Fee - payment.FeeRefunded
+	clawback, err := s.cashbackClawback(payment, payment.Amount-payment.Refunded, payment.Refunded)
+	if err != nil {
+		return err
+	}
+	err = s.checkClawback(payment, account, credit, clawback)
+	if err != nil {
+		return err
+	}
+	err = s.creditPayment(payment, account, credit)
+	if err != nil {
+		return err
+	}
 	payment.Status = types.PaymentStatusFail
-	account.Balance += payment.Amount
+	s.dropChallenge(payment)
+	s.clawbackCashback(payment, account, clawback)
 	return nil
 }
 
 func (s *Service) Repeat(paymentID string) (*types.Payment, error) {
 	pay, err := s.FindPaymentByID(paymentID)
 	if err != nil {
-		return nil, fmt.Errorf("can't find payment, error=%v", err)
+		return nil, fmt.Errorf("can't find payment, error=%w", err)
 	}
-	payment, err := s.Pay(pay.AccountID, pay.Amount, pay.Category)
+	//повтор списывается из того же конверта, если он ещё существует
+	envelope, _ := s.FindEnvelopeByID(pay.EnvelopeID)
+	payment, err := s.pay(pay.AccountID, pay.Amount, pay.Category, envelope, nil)
 	if err != nil {
-		return nil, fmt.Errorf("can't create payment again, error=%v", err)
+		return nil, fmt.Errorf("can't create payment again, error=%w", err)
 	}
 
 	return payment, nil
@@ -159,6 +399,18 @@ func (s *Service) FavoritePayment(paymentID string, name string) (*types.Favorit
 	if err != nil {
 		return nil, ErrPaymentNotFound
 	}
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkOpen(account)
+	if err != nil {
+		return nil, err
+	}
+	name, err = s.checkFavoriteName(payment.AccountID, "", name)
+	if err != nil {
+		return nil, err
+	}
 
 	favorite := &types.Favorite{
 		ID:        uuid.New().String(),
@@ -166,21 +418,18 @@ func (s *Service) FavoritePayment(paymentID string, name string) (*types.Favorit
 		Name:      name,
 		Amount:    payment.Amount,
 		Category:  payment.Category,
+		Position:  len(s.accountFavorites(payment.AccountID)),
 	}
 	s.favorites = append(s.favorites, favorite)
 	return favorite, nil
 }
 
 func (s *Service) PayFromFavorite(favoriteID string) (*types.Payment, error) {
-	findpay, err := s.FindFavoriteByID(favoriteID)
+	//избранное без полей и с фиксированной суммой - частный случай шаблона
+	pay, err := s.PayFromTemplate(favoriteID, types.TemplateOverrides{})
 
 	if err != nil {
-		return nil, ErrFavoriteNotFound
-	}
-	pay, err := s.Pay(findpay.AccountID, findpay.Amount, findpay.Category)
-
-	if err != nil {
-		return nil, ErrPaymentNotFound
+		return nil, err
 	}
 
 	return pay, nil
@@ -222,7 +471,8 @@ func (s *Service) ExportToFile(path string) error {
 	for _, account := range s.accounts {
 		str += strconv.Itoa(int(account.ID)) + ";"
 		str += string(account.Phone) + ";"
-		str += strconv.Itoa(int(account.Balance)) + "|"
+		str += strconv.Itoa(int(account.Balance)) + ";"
+		str += string(account.Currency) + "|"
 	}
 	_, err = file.Write([]byte(str))
 	if err != nil {
@@ -277,88 +527,168 @@ func (s *Service) ImportFromFile(path string) error {
 			log.Print(err)
 			return err
 		}
+		currency := types.DefaultCurrency
+		if len(splits) > 3 {
+			currency = types.Currency(splits[3])
+		}
 
 		s.accounts = append(s.accounts, &types.Account{
-			ID:      int64(id),
-			Phone:   types.Phone(phone),
-			Balance: types.Money(balance),
+			ID:       int64(id),
+			Phone:    importedPhone(phone),
+			Balance:  types.Money(balance),
+			Currency: currency,
+			Status:   types.AccountStatusActive,
+			Tier:     types.KYCTierAnonymous,
 		})
+		if int64(id) > s.nextAccountID {
+			s.nextAccountID = int64(id)
+		}
 	}
 	return nil
 }
 
 func (s *Service) Export(dir string) error {
 
-	if len(s.accounts) > 0 {
-		file, err := os.OpenFile(dir+"/accounts.dump", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
-		if err != nil {
-			log.Print(err)
-			return err
-		}
-		defer func() {
-			if cerr := file.Close(); cerr != nil {
-				if err == nil {
-					cerr = err
-				}
+	file, err := os.OpenFile(dir+"/accounts.dump", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil {
+			if err == nil {
+				cerr = err
 			}
-		}()
-		accstr := ""
-		for _, account := range s.accounts {
-			accstr += strconv.Itoa(int(account.ID)) + ";"
-			accstr += string(account.Phone) + ";"
-			accstr += strconv.Itoa(int(account.Balance)) + "\n"
 		}
-		file.WriteString(accstr)
+	}()
+	accstr := ""
+	for _, account := range s.accounts {
+		accstr += strconv.Itoa(int(account.ID)) + ";"
+		accstr += string(account.Phone) + ";"
+		accstr += strconv.Itoa(int(account.Balance)) + ";"
+		accstr += string(account.Currency) + ";"
+		accstr += string(account.Status) + ";"
+		accstr += string(account.Tier) + "\n"
 	}
+	file.WriteString(accstr)
 
-	if len(s.payments) > 0 {
-		fil, err := os.OpenFile(dir+"/payments.dump", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
-		if err != nil {
-			log.Print(err)
-			return err
-		}
-		defer func() {
-			if cerr := fil.Close(); cerr != nil {
-				if err == nil {
-					cerr = err
-				}
+	fil, err := os.OpenFile(dir+"/payments.dump", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+	defer func() {
+		if cerr := fil.Close(); cerr != nil {
+			if err == nil {
+				cerr = err
 			}
-		}()
-
-		paystr := ""
-		for _, payment := range s.payments {
-			paystr += string(payment.ID) + ";"
-			paystr += strconv.Itoa(int(payment.AccountID)) + ";"
-			paystr += strconv.Itoa(int(payment.Amount)) + ";"
-			paystr += string(payment.Category) + ";"
-			paystr += string(payment.Status) + "\n"
 		}
-		fil.WriteString(paystr)
+	}()
+
+	paystr := ""
+	for _, payment := range s.payments {
+		paystr += string(payment.ID) + ";"
+		paystr += strconv.Itoa(int(payment.AccountID)) + ";"
+		paystr += strconv.Itoa(int(payment.Amount)) + ";"
+		paystr += escape(string(payment.Category)) + ";"
+		paystr += string(payment.Status) + ";"
+		paystr += strconv.Itoa(int(payment.Fee)) + ";"
+		paystr += encodeDetails(payment.Details) + ";"
+		paystr += strconv.FormatInt(payment.CreatedAt.Unix(), 10) + ";"
+		paystr += payment.EnvelopeID + ";"
+		paystr += string(payment.Currency) + "\n"
 	}
+	fil.WriteString(paystr)
 
-	if len(s.favorites) > 0 {
-		files, err := os.OpenFile(dir+"/favorites.dump", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
-		if err != nil {
-			log.Print(err)
-			return err
-		}
-		defer func() {
-			if cerr := files.Close(); cerr != nil {
-				if err == nil {
-					cerr = err
-				}
+	files, err := os.OpenFile(dir+"/favorites.dump", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+	defer func() {
+		if cerr := files.Close(); cerr != nil {
+			if err == nil {
+				cerr = err
 			}
-		}()
-
-		favstr := ""
-		for _, favorite := range s.favorites {
-			favstr += favorite.ID + ";"
-			favstr += strconv.Itoa(int(favorite.AccountID)) + ";"
-			favstr += favorite.Name + ";"
-			favstr += strconv.Itoa(int(favorite.Amount)) + ";"
-			favstr += string(favorite.Category) + "\n"
 		}
-		files.WriteString(favstr)
+	}()
+
+	favstr := ""
+	for _, favorite := range s.favorites {
+		favstr += favorite.ID + ";"
+		favstr += strconv.Itoa(int(favorite.AccountID)) + ";"
+		favstr += escape(favorite.Name) + ";"
+		favstr += strconv.Itoa(int(favorite.Amount)) + ";"
+		favstr += escape(string(favorite.Category)) + ";"
+		favstr += strconv.Itoa(favorite.Position) + ";"
+		favstr += strconv.FormatBool(favorite.VariableAmount) + ";"
+		favstr += encodeFields(favorite.Fields) + "\n"
+	}
+	files.WriteString(favstr)
+
+	err = s.exportRefunds(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportRewards(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportSchedules(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportIdempotency(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportLimits(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportBudgets(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportCategories(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportEnvelopes(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportGoals(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportConversions(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportPhoneChanges(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportProfiles(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportUpgrades(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportDeposited(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportPINs(dir)
+	if err != nil {
+		return err
+	}
+	err = s.exportConfirmations(dir)
+	if err != nil {
+		return err
 	}
 	return nil
 }
@@ -391,11 +721,31 @@ func (s *Service) Import(dir string) error {
 				log.Print(err)
 				return err
 			}
+			//валюта появилась позже, старые счета считаются счетами в валюте по умолчанию
+			currency := types.DefaultCurrency
+			if len(splits) > 3 {
+				currency = types.Currency(splits[3])
+			}
+			//статус тоже появился позже, счета без статуса активны
+			status := types.AccountStatusActive
+			if len(splits) > 4 && splits[4] != "" {
+				status = types.AccountStatus(splits[4])
+			}
+			tier := types.KYCTierAnonymous
+			if len(splits) > 5 && splits[5] != "" {
+				tier = types.KYCTier(splits[5])
+			}
 			s.accounts = append(s.accounts, &types.Account{
-				ID:      int64(id),
-				Phone:   types.Phone(phone),
-				Balance: types.Money(balance),
+				ID:       int64(id),
+				Phone:    importedPhone(phone),
+				Balance:  types.Money(balance),
+				Currency: currency,
+				Status:   status,
+				Tier:     tier,
 			})
+			if int64(id) > s.nextAccountID {
+				s.nextAccountID = int64(id)
+			}
 		}
 	}
 	// Payments==========================================
@@ -429,12 +779,58 @@ func (s *Service) Import(dir string) error {
 			}
 			category := splits[3]
 			status := splits[4]
+			//комиссия появилась позже, в старых дампах её нет, а категория записана как есть
+			fee := 0
+			if len(splits) > 5 {
+				category, err = unescape(category)
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+				fee, err = strconv.Atoi(splits[5])
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+			}
+			var details map[string]string
+			if len(splits) > 6 {
+				details, err = decodeDetails(splits[6])
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+			}
+			var createdAt time.Time
+			if len(splits) > 7 {
+				unix, err := strconv.ParseInt(splits[7], 10, 64)
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+				createdAt = time.Unix(unix, 0)
+			}
+			envelopeID := ""
+			if len(splits) > 8 {
+				envelopeID = splits[8]
+			}
+			currency := types.DefaultCurrency
+			if len(splits) > 9 {
+				currency = types.Currency(splits[9])
+			} else if account, err := s.FindAccountByID(int64(accountid)); err == nil {
+				currency = account.Currency
+			}
 			s.payments = append(s.payments, &types.Payment{
-				ID:        id,
-				AccountID: int64(accountid),
-				Amount:    types.Money(amount),
-				Category:  types.PaymentCategory(category),
-				Status:    types.PaymentStatus(status),
+				ID:         id,
+				AccountID:  int64(accountid),
+				Amount:     types.Money(amount),
+				Currency:   currency,
+				Category:   types.PaymentCategory(category),
+				Status:     types.PaymentStatus(status),
+				Fee:        types.Money(fee),
+				Details:    details,
+				CreatedAt:  createdAt,
+				EnvelopeID: envelopeID,
 			})
 
 		}
@@ -462,22 +858,126 @@ func (s *Service) Import(dir string) error {
 				log.Print(err)
 				return err
 			}
+			//в старых дампах без порядка имя и категория записаны как есть
 			name := splits[2]
+			category := splits[4]
+			if len(splits) > 5 {
+				name, err = unescape(name)
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+				category, err = unescape(category)
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+			}
 			amount, err := strconv.Atoi(splits[3])
 			if err != nil {
 				log.Print(err)
 				return err
 			}
-			category := types.PaymentCategory(splits[4])
+			//в старых дампах порядка нет: сохраняем порядок записей в файле
+			position := len(s.accountFavorites(int64(accountid)))
+			if len(splits) > 5 {
+				position, err = strconv.Atoi(splits[5])
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+			}
+			variableAmount := false
+			var fields []types.TemplateField
+			if len(splits) > 7 {
+				variableAmount, err = strconv.ParseBool(splits[6])
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+				fields, err = decodeFields(splits[7])
+				if err != nil {
+					log.Print(err)
+					return err
+				}
+			}
 			s.favorites = append(s.favorites, &types.Favorite{
-				ID:        id,
-				AccountID: int64(accountid),
-				Name:      name,
-				Amount:    types.Money(amount),
-				Category:  types.PaymentCategory(category),
+				ID:             id,
+				AccountID:      int64(accountid),
+				Name:           name,
+				Amount:         types.Money(amount),
+				Category:       types.PaymentCategory(category),
+				Position:       position,
+				VariableAmount: variableAmount,
+				Fields:         fields,
 			})
 		}
 	}
+
+	err = s.importRefunds(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importRewards(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importSchedules(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importIdempotency(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importLimits(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importBudgets(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importCategories(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importEnvelopes(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importGoals(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importConversions(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importPhoneChanges(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importProfiles(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importUpgrades(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importDeposited(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importPINs(dir)
+	if err != nil {
+		return err
+	}
+	err = s.importConfirmations(dir)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 /*
@@ -553,81 +1053,75 @@ func (s *Service) HistoryToFiles(payments []types.Payment, dir string, records i
 	return nil
 }
 */
-func (s *Service) SumPayments(goroutines int) types.Money{
+//SumPayments возвращает сумму всех платежей, разбивая работу на goroutines частей.
+//При переполнении ошибка пишется в лог, а сумма ограничивается пределом Money
+func (s *Service) SumPayments(goroutines int) types.Money {
+	sum, err := s.SumPaymentsChecked(goroutines)
+	if err != nil {
+		log.Print(err)
+	}
+	return sum
+}
 
+//SumPaymentsChecked считает сумму всех платежей как SumPayments, но сообщает
+//о переполнении ошибкой ErrMoneyOverflow вместе с ограниченной суммой
+func (s *Service) SumPaymentsChecked(goroutines int) (types.Money, error) {
+	if goroutines < 1 {
+		goroutines = 1
+	}
 	wg := sync.WaitGroup{}
-	mu:=sync.Mutex{}
-	sum:=types.Money(0)
-
-	if goroutines <2 {
-	wg.Add(1)
-	go func() {
-       
-		defer wg.Done()
-		val:= types.Money(0)
-
-		for _, payment := range s.payments {
-			
-			val+=payment.Amount
+	mu := sync.Mutex{}
+	sum := types.Money(0)
+	overflow := false
+	kol := len(s.payments) / goroutines
+
+	//saturate возвращает предел Money со знаком слагаемого, вызвавшего переполнение
+	saturate := func(term types.Money) types.Money {
+		if term < 0 {
+			return types.MinMoney
 		}
-		mu.Lock()
-		defer mu.Unlock()
-		sum+=val
-
-	}()
-	wg.Wait()
-	 }  else{
-   
-   wg:=sync.WaitGroup{}
-
-   mu:= sync.Mutex{}
-
-   sum:=types.Money(0)
-   
-   kol:= int(len(s.payments)/goroutines) 
-     
-     i:=0
-   for i = 0; i < goroutines-1; i++ {
-
-	wg.Add(1)
-	go func (index int){
+		return types.MaxMoney
+	}
 
+	add := func(payments []*types.Payment) {
 		defer wg.Done()
-        val:=types.Money(0)
-	 
-		payments:=s.payments[index*kol : (index+1)*kol]
-
-
+		val := types.Money(0)
 		for _, payment := range payments {
-			
-			val+=payment.Amount
+			next, err := val.Add(payment.Amount)
+			if err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if !overflow {
+					overflow, sum = true, saturate(payment.Amount)
+				}
+				return
+			}
+			val = next
 		}
 		mu.Lock()
 		defer mu.Unlock()
-		sum+=val
+		if overflow {
+			return
+		}
+		next, err := sum.Add(val)
+		if err != nil {
+			overflow, next = true, saturate(val)
+		}
+		sum = next
+	}
 
-	}(i)
+	for i := 0; i < goroutines-1; i++ {
+		wg.Add(1)
+		go add(s.payments[i*kol : (i+1)*kol])
 	}
-   
 	wg.Add(1)
+	go add(s.payments[(goroutines-1)*kol:])
+	wg.Wait()
 
-	go func (){
-     defer wg.Done()
-	 val:=types.Money(0)
-	 payments:= s.payments[i*kol:]
-	 for _, payments := range payments  {
-        
-		val+=payments.Amount
-	 }
-	 mu.Lock()
-	 defer mu.Unlock()
-	 sum+=val
-
- 	}()
- wg.Wait()
- return sum
-}
-return sum
+	if overflow {
+		return sum, types.ErrMoneyOverflow
+	}
+	return sum, nil
 }
 /*
 func (s *Service) SumPaymentsWithProgress() <-chan types.Progress {
@@ -82,6 +82,43 @@ func TestService_RegisterAccount_success(t *testing.T) {
 	}
 }
 
+func TestService_Deposit_overflow(t *testing.T) {
+	s := newTestService()
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, types.MaxMoney)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1)
+	if err != types.ErrMoneyOverflow || account.Balance != types.MaxMoney {
+		t.Errorf("Deposit(): must return ErrMoneyOverflow, returned = %v, balance = %d", err, account.Balance)
+	}
+
+	envelope, err := s.CreateEnvelope(account.ID, "rent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.MoveToEnvelope(envelope.ID, types.MaxMoney)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.MoveFromEnvelope(envelope.ID, types.MaxMoney)
+	if err != types.ErrMoneyOverflow || account.Balance != 1 || envelope.Balance != types.MaxMoney {
+		t.Errorf("MoveFromEnvelope(): must return ErrMoneyOverflow, returned = %v, balances = %d, %d", err, account.Balance, envelope.Balance)
+	}
+	err = s.RemoveEnvelope(envelope.ID)
+	if err != types.ErrMoneyOverflow || account.Balance != 1 {
+		t.Errorf("RemoveEnvelope(): must return ErrMoneyOverflow, returned = %v, balance = %d", err, account.Balance)
+	}
+}
+
 func TestService_FindPaymentByID_success(t *testing.T) {
 	//создаём сервис
 	s := newTestService()
@@ -392,12 +429,75 @@ func TestService_Export_success(t *testing.T) {
     t.Errorf("method Pay returned not nil error, err => %v", err)
   }
   
-  err = svc.Export("../../data")
+  err = svc.Export(t.TempDir())
   if err != nil {
     t.Errorf("method Export returned not nil error, err => %v", err)
   }
 }
 
+func TestService_Export_category(t *testing.T) {
+	s := newTestService()
+	account, err := s.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Deposit(account.ID, 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetFeeRule(types.FeeRule{Category: "a;b", Fixed: 1_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := s.Pay(account.ID, 10_00, "a;b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.FavoritePayment(payment.ID, "ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatalf("Import(): error = %v", err)
+	}
+	got, err := imported.FindPaymentByID(payment.ID)
+	if err != nil || got.Category != "a;b" || got.Fee != 1_00 || got.Status != types.PaymentStatusInProgress {
+		t.Errorf("Import(): payment = %v, error = %v", got, err)
+	}
+	favorites := imported.Favorites()
+	if len(favorites) != 1 || favorites[0].Category != "a;b" {
+		t.Errorf("Import(): favorites = %v", favorites)
+	}
+}
+
+func TestService_SumPaymentsChecked(t *testing.T) {
+	s := newTestService()
+	for i, amount := range []types.Money{types.MaxMoney - 10, 5, 5, 1} {
+		s.payments = append(s.payments, &types.Payment{ID: fmt.Sprint(i), Amount: amount})
+	}
+
+	sum, err := s.SumPaymentsChecked(2)
+	if err != types.ErrMoneyOverflow || sum != types.MaxMoney {
+		t.Errorf("SumPaymentsChecked(): sum = %d, error = %v", sum, err)
+	}
+
+	s.payments = s.payments[:3]
+	for _, goroutines := range []int{0, 1, 2, 3, 5} {
+		sum, err = s.SumPaymentsChecked(goroutines)
+		if err != nil || sum != types.MaxMoney {
+			t.Errorf("SumPaymentsChecked(%d): sum = %d, error = %v", goroutines, sum, err)
+		}
+	}
+}
+
 func BenchmarkSumPayments(b *testing.B) {
 
 	s := Service{}
@@ -0,0 +1,120 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+var ErrAccountFrozen = errors.New("account is frozen")
+var ErrAccountClosed = errors.New("account is closed")
+var ErrAccountNotEmpty = errors.New("account balance is not zero")
+var ErrInvalidPayout = errors.New("invalid payout account")
+
+//Freeze временно блокирует счёт: пополнения, платежи, обмены и движения по конвертам
+//запрещены, а возвраты и отмены прежних платежей по-прежнему зачисляются
+func (s *Service) Freeze(accountID int64) error {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+	account.Status = types.AccountStatusFrozen
+	return nil
+}
+
+//Unfreeze снимает блокировку со счёта
+func (s *Service) Unfreeze(accountID int64) error {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+	account.Status = types.AccountStatusActive
+	return nil
+}
+
+//Close закрывает счёт. Если на счёте или в его конвертах остались деньги, нужен
+//payoutAccountID - активный счёт в той же валюте, куда переводится остаток; ноль - без перевода.
+//Замороженный счёт закрыть нельзя, иначе остаток ушёл бы в обход блокировки
+func (s *Service) Close(accountID int64, payoutAccountID int64) error {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	err = checkActive(account)
+	if err != nil {
+		return err
+	}
+
+	envelopes, _ := s.EnvelopesByAccount(accountID)
+	total := account.Balance
+	for _, envelope := range envelopes {
+		total += envelope.Balance
+	}
+	if total != 0 {
+		if payoutAccountID == 0 {
+			return ErrAccountNotEmpty
+		}
+		if payoutAccountID == accountID {
+			return ErrInvalidPayout
+		}
+		payout, err := s.FindAccountByID(payoutAccountID)
+		if err != nil {
+			return err
+		}
+		err = checkActive(payout)
+		if err != nil {
+			return err
+		}
+		if payout.Currency != account.Currency {
+			return ErrCurrencyMismatch
+		}
+		err = s.credit(payout, total)
+		if err != nil {
+			return err
+		}
+		account.Balance = 0
+		for _, envelope := range envelopes {
+			envelope.Balance = 0
+		}
+	}
+	account.Status = types.AccountStatusClosed
+	return nil
+}
+
+//Reopen снова открывает закрытый счёт
+func (s *Service) Reopen(accountID int64) error {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if account.Status != types.AccountStatusClosed {
+		return nil
+	}
+	account.Status = types.AccountStatusActive
+	return nil
+}
+
+//checkActive разрешает операцию только по активному счёту
+func checkActive(account *types.Account) error {
+	switch account.Status {
+	case types.AccountStatusFrozen:
+		return ErrAccountFrozen
+	case types.AccountStatusClosed:
+		return ErrAccountClosed
+	}
+	return nil
+}
+
+//checkOpen разрешает операцию по активному и замороженному счёту
+func checkOpen(account *types.Account) error {
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+	return nil
+}
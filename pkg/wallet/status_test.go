@@ -0,0 +1,148 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestService_Freeze(t *testing.T) {
+	s := newTestService()
+	account, payments, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := s.CreateEnvelope(account.ID, "rent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Freeze(account.ID)
+	if err != nil {
+		t.Fatalf("Freeze(): error = %v", err)
+	}
+	if account.Status != types.AccountStatusFrozen {
+		t.Errorf("Freeze(): status = %v", account.Status)
+	}
+	err = s.Deposit(account.ID, 1)
+	if err != ErrAccountFrozen {
+		t.Errorf("Deposit(): must return ErrAccountFrozen, returned = %v", err)
+	}
+	_, err = s.Pay(account.ID, 1, "auto")
+	if err != ErrAccountFrozen {
+		t.Errorf("Pay(): must return ErrAccountFrozen, returned = %v", err)
+	}
+	err = s.MoveToEnvelope(envelope.ID, 1)
+	if err != ErrAccountFrozen {
+		t.Errorf("MoveToEnvelope(): must return ErrAccountFrozen, returned = %v", err)
+	}
+	err = s.Close(account.ID, 0)
+	if err != ErrAccountFrozen || account.Status != types.AccountStatusFrozen {
+		t.Errorf("Close(): must return ErrAccountFrozen, returned = %v", err)
+	}
+	//возврат прежнего платежа на замороженный счёт разрешён
+	_, err = s.Refund(payments[0].ID, 1)
+	if err != nil {
+		t.Errorf("Refund(): error = %v", err)
+	}
+
+	err = s.Unfreeze(account.ID)
+	if err != nil {
+		t.Fatalf("Unfreeze(): error = %v", err)
+	}
+	_, err = s.Pay(account.ID, 1, "auto")
+	if err != nil {
+		t.Errorf("Pay(): error after Unfreeze = %v", err)
+	}
+	err = s.Freeze(0)
+	if err != ErrAccountNotFound {
+		t.Errorf("Freeze(): must return ErrAccountNotFound, returned = %v", err)
+	}
+}
+
+func TestService_Close(t *testing.T) {
+	s := newTestService()
+	account, payments, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := s.CreateEnvelope(account.ID, "rent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.MoveToEnvelope(envelope.ID, 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payout, err := s.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dollars, err := s.RegisterAccountInCurrency("+992000000002", types.USD)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Close(account.ID, 0)
+	if err != ErrAccountNotEmpty {
+		t.Errorf("Close(): must return ErrAccountNotEmpty, returned = %v", err)
+	}
+	err = s.Close(account.ID, account.ID)
+	if err != ErrInvalidPayout {
+		t.Errorf("Close(): must return ErrInvalidPayout, returned = %v", err)
+	}
+	err = s.Close(account.ID, dollars.ID)
+	if err != ErrCurrencyMismatch {
+		t.Errorf("Close(): must return ErrCurrencyMismatch, returned = %v", err)
+	}
+
+	total := account.Balance + envelope.Balance
+	err = s.Close(account.ID, payout.ID)
+	if err != nil {
+		t.Fatalf("Close(): error = %v", err)
+	}
+	if account.Status != types.AccountStatusClosed || account.Balance != 0 || envelope.Balance != 0 || payout.Balance != total {
+		t.Errorf("Close(): account = %v, envelope = %v, payout = %v", account, envelope, payout)
+	}
+
+	_, err = s.Refund(payments[0].ID, 1)
+	if err != ErrAccountClosed {
+		t.Errorf("Refund(): must return ErrAccountClosed, returned = %v", err)
+	}
+	_, err = s.FavoritePayment(payments[0].ID, "auto")
+	if err != ErrAccountClosed {
+		t.Errorf("FavoritePayment(): must return ErrAccountClosed, returned = %v", err)
+	}
+	err = s.Freeze(account.ID)
+	if err != ErrAccountClosed {
+		t.Errorf("Freeze(): must return ErrAccountClosed, returned = %v", err)
+	}
+	err = s.Close(payout.ID, account.ID)
+	if err != ErrAccountClosed {
+		t.Errorf("Close(): payout to closed account must return ErrAccountClosed, returned = %v", err)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := imported.FindAccountByID(account.ID)
+	if err != nil || got.Status != types.AccountStatusClosed {
+		t.Errorf("Import(): account = %v, error = %v", got, err)
+	}
+
+	err = s.Reopen(account.ID)
+	if err != nil {
+		t.Fatalf("Reopen(): error = %v", err)
+	}
+	err = s.Deposit(account.ID, 1)
+	if err != nil {
+		t.Errorf("Deposit(): error after Reopen = %v", err)
+	}
+}
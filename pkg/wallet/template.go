@@ -0,0 +1,149 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+var ErrInvalidTemplate = errors.New("invalid template")
+var ErrTemplateAmountFixed = errors.New("template amount is fixed")
+var ErrTemplateAmountRequired = errors.New("template amount is required")
+var ErrTemplateFieldRequired = errors.New("template field is required")
+var ErrTemplateFieldUnknown = errors.New("unknown template field")
+
+//SetTemplate превращает избранное в шаблон: задаёт, вводится ли сумма при каждом платеже,
+//и какие поля нужно заполнить. Названия полей не могут повторяться
+func (s *Service) SetTemplate(favoriteID string, variableAmount bool, fields []types.TemplateField) (*types.Favorite, error) {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, field := range fields {
+		if strings.TrimSpace(field.Name) == "" || names[field.Name] {
+			return nil, ErrInvalidTemplate
+		}
+		names[field.Name] = true
+	}
+
+	favorite.VariableAmount = variableAmount
+	favorite.Fields = append([]types.TemplateField(nil), fields...)
+	return favorite, nil
+}
+
+//PayFromTemplate проверяет переданные значения по шаблону и выполняет платёж.
+//Для фиксированной суммы overrides.Amount должен быть нулём или совпадать с суммой шаблона
+func (s *Service) PayFromTemplate(favoriteID string, overrides types.TemplateOverrides) (*types.Payment, error) {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := favorite.Amount
+	if favorite.VariableAmount {
+		if overrides.Amount == 0 {
+			return nil, ErrTemplateAmountRequired
+		}
+		amount = overrides.Amount
+	} else if overrides.Amount != 0 && overrides.Amount != favorite.Amount {
+		return nil, ErrTemplateAmountFixed
+	}
+
+	details, err := templateDetails(favorite, overrides.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	//детали нужны уже при оплате: их видят уведомления о подтверждении и бюджете
+	return s.pay(favorite.AccountID, amount, favorite.Category, nil, details)
+}
+
+//templateDetails проверяет значения полей шаблона и возвращает непустые из них
+func templateDetails(favorite *types.Favorite, values map[string]string) (map[string]string, error) {
+	known := make(map[string]bool)
+	for _, field := range favorite.Fields {
+		known[field.Name] = true
+	}
+	for name := range values {
+		if !known[name] {
+			return nil, fmt.Errorf("%w: %s", ErrTemplateFieldUnknown, name)
+		}
+	}
+
+	var details map[string]string
+	for _, field := range favorite.Fields {
+		value := strings.TrimSpace(values[field.Name])
+		if value == "" {
+			if field.Required {
+				return nil, fmt.Errorf("%w: %s", ErrTemplateFieldRequired, field.Name)
+			}
+			continue
+		}
+		if details == nil {
+			details = make(map[string]string)
+		}
+		details[field.Name] = value
+	}
+	return details, nil
+}
+
+//encodeFields кодирует поля шаблона для дампа, сохраняя их порядок
+func encodeFields(fields []types.TemplateField) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		required := "0"
+		if field.Required {
+			required = "1"
+		}
+		parts[i] = url.QueryEscape(field.Name) + "=" + required
+	}
+	return strings.Join(parts, "&")
+}
+
+func decodeFields(s string) ([]types.TemplateField, error) {
+	if s == "" {
+		return nil, nil
+	}
+	fields := make([]types.TemplateField, 0)
+	for _, part := range strings.Split(s, "&") {
+		splits := strings.Split(part, "=")
+		if len(splits) != 2 {
+			return nil, fmt.Errorf("wrong template field %q", part)
+		}
+		name, err := url.QueryUnescape(splits[0])
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, types.TemplateField{Name: name, Required: splits[1] == "1"})
+	}
+	return fields, nil
+}
+
+//encodeDetails кодирует значения полей платежа для дампа
+func encodeDetails(details map[string]string) string {
+	values := url.Values{}
+	for name, value := range details {
+		values.Set(name, value)
+	}
+	return values.Encode()
+}
+
+func decodeDetails(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	details := make(map[string]string)
+	for name := range values {
+		details[name] = values.Get(name)
+	}
+	return details, nil
+}
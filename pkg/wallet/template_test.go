@@ -0,0 +1,78 @@
+package wallet
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Behzod01/wallet/pkg/types"
+)
+
+func TestService_PayFromTemplate(t *testing.T) {
+	s := newTestService()
+	_, payment, favorites := addFavorites(t, s, "electricity")
+	fields := []types.TemplateField{{Name: "meter", Required: true}, {Name: "comment"}}
+	_, err := s.SetTemplate(favorites[0].ID, true, fields)
+	if err != nil {
+		t.Fatalf("SetTemplate(): error = %v", err)
+	}
+
+	_, err = s.PayFromTemplate(favorites[0].ID, types.TemplateOverrides{Fields: map[string]string{"meter": "42"}})
+	if err != ErrTemplateAmountRequired {
+		t.Errorf("PayFromTemplate(): must return ErrTemplateAmountRequired, returned = %v", err)
+	}
+	_, err = s.PayFromTemplate(favorites[0].ID, types.TemplateOverrides{Amount: 10_00, Fields: map[string]string{"meter": " "}})
+	if !errors.Is(err, ErrTemplateFieldRequired) {
+		t.Errorf("PayFromTemplate(): must return ErrTemplateFieldRequired, returned = %v", err)
+	}
+	_, err = s.PayFromTemplate(favorites[0].ID, types.TemplateOverrides{Amount: 10_00, Fields: map[string]string{"meter": "42", "room": "1"}})
+	if !errors.Is(err, ErrTemplateFieldUnknown) {
+		t.Errorf("PayFromTemplate(): must return ErrTemplateFieldUnknown, returned = %v", err)
+	}
+
+	paid, err := s.PayFromTemplate(favorites[0].ID, types.TemplateOverrides{Amount: 10_00, Fields: map[string]string{"meter": "42"}})
+	if err != nil {
+		t.Fatalf("PayFromTemplate(): error = %v", err)
+	}
+	if paid.Amount != 10_00 || paid.Category != payment.Category || !reflect.DeepEqual(paid.Details, map[string]string{"meter": "42"}) {
+		t.Errorf("PayFromTemplate(): payment = %v", paid)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	favorite, _ := imported.FindFavoriteByID(favorites[0].ID)
+	if !favorite.VariableAmount || !reflect.DeepEqual(favorite.Fields, fields) {
+		t.Errorf("Import(): favorite = %v", favorite)
+	}
+	got, _ := imported.FindPaymentByID(paid.ID)
+	if !reflect.DeepEqual(got.Details, paid.Details) {
+		t.Errorf("Import(): details = %v", got.Details)
+	}
+}
+
+func TestService_PayFromTemplate_fixedAmount(t *testing.T) {
+	s := newTestService()
+	_, payment, favorites := addFavorites(t, s, "mobile")
+
+	_, err := s.PayFromTemplate(favorites[0].ID, types.TemplateOverrides{Amount: payment.Amount + 1})
+	if err != ErrTemplateAmountFixed {
+		t.Errorf("PayFromTemplate(): must return ErrTemplateAmountFixed, returned = %v", err)
+	}
+	paid, err := s.PayFromFavorite(favorites[0].ID)
+	if err != nil || paid.Amount != payment.Amount || paid.Details != nil {
+		t.Errorf("PayFromFavorite(): payment = %v, error = %v", paid, err)
+	}
+
+	_, err = s.SetTemplate(favorites[0].ID, false, []types.TemplateField{{Name: "a"}, {Name: "a"}})
+	if err != ErrInvalidTemplate {
+		t.Errorf("SetTemplate(): must return ErrInvalidTemplate, returned = %v", err)
+	}
+}